@@ -0,0 +1,200 @@
+package gotransform
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ArchiveFormat is the file format of an ArchiveOutput.
+type ArchiveFormat int
+
+const (
+	// ArchiveZip writes a zip file.
+	ArchiveZip ArchiveFormat = iota
+	// ArchiveTarGz writes a gzip-compressed tar file.
+	ArchiveTarGz
+)
+
+// archiveTime is the modification time of all files in an archive. Zip files cannot
+// represent times before 1980.
+var archiveTime = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// ArchiveOutput is an Output that collects all files in memory and writes them to a zip or
+// tar.gz archive when it is closed. The archive is deterministic: its entries are sorted by
+// name and all of them have the same modification time and permissions, so generating the
+// same files twice yields identical archives.
+type ArchiveOutput struct {
+	path, root string
+	format     ArchiveFormat
+	mutex      sync.Mutex
+	files      map[string][]byte
+}
+
+// NewArchiveOutput creates an output writing to the archive at the given path, using the
+// given format. All files have to be written below the root directory; their paths in
+// the archive are relative to it. To write all output of a pipeline to an archive, use
+//     archive := gotransform.NewArchiveOutput("gen.zip", gotransform.ArchiveZip, outputPath)
+//     gotransform.SetOutput(archive)
+//     err := gotransform.Apply(inputPath, transformations)
+//     ...
+//     err = archive.Close()
+// Note that the archive starts out empty, so all files count as created. Handlers that need
+// to run the generated code, such as the InceptionTemplater, do not work with an archive.
+func NewArchiveOutput(path string, format ArchiveFormat, root string) *ArchiveOutput {
+	return &ArchiveOutput{
+		path:   path,
+		root:   root,
+		format: format,
+		files:  make(map[string][]byte),
+	}
+}
+
+// ArchiveFormatFor determines the archive format from the extension of a path, i.e. .zip
+// or .tar.gz (also .tgz).
+func ArchiveFormatFor(path string) (ArchiveFormat, error) {
+	lower := strings.ToLower(path)
+	switch {
+	case strings.HasSuffix(lower, ".zip"):
+		return ArchiveZip, nil
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return ArchiveTarGz, nil
+	}
+	return 0, errors.Errorf("ArchiveFormatFor: Unknown archive format of %s", path)
+}
+
+// entryName converts a path to the name of its entry in the archive.
+func (ao *ArchiveOutput) entryName(path string) (string, error) {
+	relative, err := filepath.Rel(ao.root, path)
+	if err != nil {
+		return "", errors.Wrapf(err, "ArchiveOutput: %s is not within %s", path, ao.root)
+	}
+	relative = filepath.ToSlash(relative)
+	if relative == ".." || strings.HasPrefix(relative, "../") {
+		return "", errors.Errorf("ArchiveOutput: %s is not within %s", path, ao.root)
+	}
+	return relative, nil
+}
+
+func (ao *ArchiveOutput) ReadFile(path string) ([]byte, error) {
+	name, err := ao.entryName(path)
+	if err != nil {
+		return nil, err
+	}
+	ao.mutex.Lock()
+	defer ao.mutex.Unlock()
+	data, ok := ao.files[name]
+	if !ok {
+		return nil, &os.PathError{Op: "read", Path: path, Err: os.ErrNotExist}
+	}
+	return data, nil
+}
+
+func (ao *ArchiveOutput) WriteFile(path string, data []byte) error {
+	name, err := ao.entryName(path)
+	if err != nil {
+		return err
+	}
+	ao.mutex.Lock()
+	defer ao.mutex.Unlock()
+	ao.files[name] = append([]byte(nil), data...)
+	return nil
+}
+
+func (ao *ArchiveOutput) Remove(path string) error {
+	name, err := ao.entryName(path)
+	if err != nil {
+		return err
+	}
+	ao.mutex.Lock()
+	defer ao.mutex.Unlock()
+	if _, ok := ao.files[name]; !ok {
+		return &os.PathError{Op: "remove", Path: path, Err: os.ErrNotExist}
+	}
+	delete(ao.files, name)
+	return nil
+}
+
+// Close writes the archive to the file system.
+func (ao *ArchiveOutput) Close() error {
+	ao.mutex.Lock()
+	defer ao.mutex.Unlock()
+	names := make([]string, 0, len(ao.files))
+	for name := range ao.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if err := os.MkdirAll(filepath.Dir(ao.path), os.ModePerm); err != nil {
+		return errors.Wrapf(err, "ArchiveOutput: Failed to create directory for %s", ao.path)
+	}
+	file, err := os.Create(ao.path)
+	if err != nil {
+		return errors.Wrapf(err, "ArchiveOutput: Failed to create %s", ao.path)
+	}
+	defer file.Close()
+	switch ao.format {
+	case ArchiveZip:
+		err = ao.writeZip(file, names)
+	case ArchiveTarGz:
+		err = ao.writeTarGz(file, names)
+	default:
+		err = errors.Errorf("Unknown archive format %d", ao.format)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "ArchiveOutput: Failed to write %s", ao.path)
+	}
+	return errors.Wrapf(file.Close(), "ArchiveOutput: Failed to close %s", ao.path)
+}
+
+func (ao *ArchiveOutput) writeZip(w io.Writer, names []string) error {
+	archive := zip.NewWriter(w)
+	for _, name := range names {
+		header := &zip.FileHeader{Name: name, Method: zip.Deflate, Modified: archiveTime}
+		header.SetMode(0644)
+		entry, err := archive.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		if _, err := entry.Write(ao.files[name]); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+func (ao *ArchiveOutput) writeTarGz(w io.Writer, names []string) error {
+	compressed := gzip.NewWriter(w)
+	compressed.ModTime = archiveTime
+	archive := tar.NewWriter(compressed)
+	for _, name := range names {
+		data := ao.files[name]
+		header := &tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     name,
+			Size:     int64(len(data)),
+			Mode:     0644,
+			ModTime:  archiveTime,
+			Format:   tar.FormatPAX,
+		}
+		if err := archive.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := archive.Write(data); err != nil {
+			return err
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return err
+	}
+	return compressed.Close()
+}
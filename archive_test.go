@@ -0,0 +1,112 @@
+package gotransform
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestArchiveOutput(t *testing.T) {
+	tests := []struct {
+		name   string
+		format ArchiveFormat
+		read   func(t *testing.T, data []byte) map[string]string
+	}{
+		{name: "zip", format: ArchiveZip, read: readZip},
+		{name: "tar.gz", format: ArchiveTarGz, read: readTarGz},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			root := filepath.Join(dir, "out")
+			var archives [][]byte
+			for i := 0; i < 2; i++ {
+				path := filepath.Join(dir, "gen"+string(rune('0'+i)))
+				archive := NewArchiveOutput(path, test.format, root)
+				SetOutput(archive)
+				for _, name := range []string{"b/c.go", "a.go", "stale.go"} {
+					if _, err := WriteFileAtomic(filepath.Join(root, name), []byte(name)); err != nil {
+						t.Fatal(err)
+					}
+				}
+				removeErr := RemoveFile(filepath.Join(root, "stale.go"))
+				_, outsideErr := WriteFileAtomic(filepath.Join(dir, "outside.go"), nil)
+				SetOutput(FileSystem)
+				if removeErr != nil {
+					t.Fatal(removeErr)
+				}
+				if outsideErr == nil || !strings.Contains(outsideErr.Error(), "is not within") {
+					t.Errorf("Expected writing outside of the root to fail, got %v", outsideErr)
+				}
+				if err := archive.Close(); err != nil {
+					t.Fatal(err)
+				}
+				data, err := os.ReadFile(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				archives = append(archives, data)
+			}
+			if !bytes.Equal(archives[0], archives[1]) {
+				t.Error("Expected identical archives for identical files")
+			}
+			want := map[string]string{"a.go": "a.go", "b/c.go": "b/c.go"}
+			if got := test.read(t, archives[0]); !reflect.DeepEqual(got, want) {
+				t.Errorf("Expected entries %v, got %v", want, got)
+			}
+		})
+	}
+}
+
+func readZip(t *testing.T, data []byte) map[string]string {
+	t.Helper()
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := make(map[string]string)
+	for _, f := range reader.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[f.Name] = string(content)
+	}
+	return files
+}
+
+func readTarGz(t *testing.T, data []byte) map[string]string {
+	t.Helper()
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader := tar.NewReader(gz)
+	files := make(map[string]string)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[header.Name] = string(content)
+	}
+	return files
+}
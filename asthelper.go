@@ -0,0 +1,184 @@
+package gotransform
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// AppendDeclarations appends declarations given as Go source (without a package clause) to
+// a file. Imports in the source are added to the imports of the file. All existing nodes of
+// the file stay untouched, so references to them and their type information remain valid;
+// there is no type information for the new declarations, though.
+func AppendDeclarations(fset *token.FileSet, file *ast.File, src []byte) error {
+	// The printer places comments by comparing their offsets and lines with those of the
+	// other nodes, so the new declarations are parsed as a separate file whose code starts
+	// behind the offsets and lines of everything in the existing file.
+	end := file.End()
+	if len(file.Comments) > 0 && file.Comments[len(file.Comments)-1].End() > end {
+		end = file.Comments[len(file.Comments)-1].End()
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "package %s", file.Name.Name)
+	buf.Write(bytes.Repeat([]byte("\n"), fset.Position(end).Offset+1))
+	buf.Write(src)
+	filename := fset.Position(file.Package).Filename
+	appended, err := parser.ParseFile(fset, filename, buf.Bytes(), parser.ParseComments)
+	if err != nil {
+		return errors.Wrap(err, "AppendDeclarations: Failed to parse declarations")
+	}
+	for _, spec := range appended.Imports {
+		importPath, _ := strconv.Unquote(spec.Path.Value)
+		if spec.Name != nil {
+			astutil.AddNamedImport(fset, file, spec.Name.Name, importPath)
+		} else {
+			astutil.AddImport(fset, file, importPath)
+		}
+	}
+	for _, decl := range appended.Decls {
+		if genDecl, ok := decl.(*ast.GenDecl); !ok || genDecl.Tok != token.IMPORT {
+			file.Decls = append(file.Decls, decl)
+		}
+	}
+	for _, group := range appended.Comments {
+		if group.Pos() > appended.Name.End() && !importComment(appended, group) {
+			file.Comments = append(file.Comments, group)
+		}
+	}
+	return nil
+}
+
+// importComment checks whether a comment group belongs to an import declaration.
+func importComment(f *ast.File, group *ast.CommentGroup) bool {
+	for _, decl := range f.Decls {
+		if genDecl, ok := decl.(*ast.GenDecl); ok && genDecl.Tok == token.IMPORT {
+			if group.Pos() >= genDecl.Pos() && group.End() <= genDecl.End() || group == genDecl.Doc {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// replaceFile parses the given source and replaces the AST of the file with it.
+func replaceFile(fset *token.FileSet, file *ast.File, filename string, src []byte) error {
+	replacement, err := parser.ParseFile(fset, filename, src, parser.ParseComments)
+	if err != nil {
+		return errors.Wrap(err, "Failed to parse modified file")
+	}
+	*file = *replacement
+	return nil
+}
+
+// removeComments removes all comments within the given node from the comment list of a
+// file, as well as the additional comment groups (usually the doc and line comments of
+// the node). Otherwise, the printer would still output them after the node was removed.
+func removeComments(f *ast.File, node ast.Node, additional ...*ast.CommentGroup) {
+	comments := f.Comments[:0]
+	for _, group := range f.Comments {
+		if group.Pos() >= node.Pos() && group.End() <= node.End() {
+			continue
+		}
+		if containsCommentGroup(additional, group) {
+			continue
+		}
+		comments = append(comments, group)
+	}
+	f.Comments = comments
+}
+
+func containsCommentGroup(groups []*ast.CommentGroup, group *ast.CommentGroup) bool {
+	for _, g := range groups {
+		if g == group {
+			return true
+		}
+	}
+	return false
+}
+
+var positionType = reflect.TypeOf(token.NoPos)
+
+// reposition moves all nodes within the given node to a single position. This is necessary
+// when inserting nodes that were parsed separately into a file, since the printer relies
+// on the positions to place the comments of the file. Unset positions are kept unset,
+// as some of them carry meaning (e.g. CallExpr.Ellipsis).
+func reposition(node ast.Node, pos token.Pos) {
+	ast.Inspect(node, func(n ast.Node) bool {
+		if n == nil {
+			return false
+		}
+		v := reflect.ValueOf(n)
+		if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+			return true
+		}
+		v = v.Elem()
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Field(i)
+			if field.Type() == positionType && field.Interface().(token.Pos) != token.NoPos {
+				field.Set(reflect.ValueOf(pos))
+			}
+		}
+		return true
+	})
+}
+
+// importName returns the name under which an import is referenced in a file. If the
+// import is not named explicitly, the name of the package is guessed from its path, see
+// assumedPackageName; use resolvedImportName where the actual name matters.
+func importName(spec *ast.ImportSpec) string {
+	if spec.Name != nil {
+		return spec.Name.Name
+	}
+	importPath, err := strconv.Unquote(spec.Path.Value)
+	if err != nil {
+		return ""
+	}
+	return assumedPackageName(importPath)
+}
+
+// usedPackageNames collects the names of all packages that are referenced in a selector
+// expression within the given node.
+func usedPackageNames(node ast.Node) map[string]bool {
+	used := make(map[string]bool)
+	ast.Inspect(node, func(n ast.Node) bool {
+		sel, ok := n.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		// package names are never resolved to objects in the file scope
+		if ident, ok := sel.X.(*ast.Ident); ok && ident.Obj == nil {
+			used[ident.Name] = true
+		}
+		return true
+	})
+	return used
+}
+
+// deleteDeadImports removes all imports from a file that were referenced before (as given
+// by usedBefore, see usedPackageNames) and are not referenced anymore.
+func deleteDeadImports(fset *token.FileSet, f *ast.File, usedBefore map[string]bool) {
+	usedAfter := usedPackageNames(f)
+	var dead []*ast.ImportSpec
+	for _, spec := range f.Imports {
+		name := importName(spec)
+		if name != "_" && name != "." && usedBefore[name] && !usedAfter[name] {
+			dead = append(dead, spec)
+		}
+	}
+	// NB: deleting an import modifies f.Imports, so we cannot delete while iterating
+	for _, spec := range dead {
+		importPath, _ := strconv.Unquote(spec.Path.Value)
+		if spec.Name != nil {
+			astutil.DeleteNamedImport(fset, f, spec.Name.Name, importPath)
+		} else {
+			astutil.DeleteImport(fset, f, importPath)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package gotransform
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"testing"
+)
+
+func TestAppendDeclarations(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		add  []string
+		want string
+	}{
+		{
+			name: "comments stay in place",
+			src:  "package p\n\n// T is a type.\ntype T struct {\n\tA int // a\n\n\t// B is b.\n\tB int\n}\n\n// trailing comment\n",
+			add:  []string{"// U is generated.\ntype U struct{}\n"},
+			want: "package p\n\n// T is a type.\ntype T struct {\n\tA int // a\n\n\t// B is b.\n\tB int\n}\n\n// trailing comment\n\n// U is generated.\ntype U struct{}\n",
+		},
+		{
+			name: "repeated with imports",
+			src:  "package p\n\nimport \"fmt\"\n\nvar _ = fmt.Sprint\n",
+			add: []string{
+				"import \"strings\"\n\n// A is generated.\nvar A = strings.ToUpper\n",
+				"import (\n\t\"fmt\"\n\tstr \"strconv\"\n)\n\n// B is generated.\nvar B = fmt.Sprint(str.Itoa(1)) // b\n",
+			},
+			want: "package p\n\nimport (\n\t\"fmt\"\n\tstr \"strconv\"\n\t\"strings\"\n)\n\nvar _ = fmt.Sprint\n\n// A is generated.\nvar A = strings.ToUpper\n\n// B is generated.\nvar B = fmt.Sprint(str.Itoa(1)) // b\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fset := token.NewFileSet()
+			file, err := parser.ParseFile(fset, "p.go", test.src, parser.ParseComments)
+			if err != nil {
+				t.Fatal(err)
+			}
+			last := file.Decls[len(file.Decls)-1]
+			for _, src := range test.add {
+				if err := AppendDeclarations(fset, file, []byte(src)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if !containsDecl(file.Decls, last) {
+				t.Errorf("The existing declarations were replaced")
+			}
+			checkSource(t, formatNode(t, FileContext{File: file, FileSet: fset}), test.want)
+		})
+	}
+}
+
+func containsDecl(decls []ast.Decl, decl ast.Decl) bool {
+	for _, d := range decls {
+		if d == decl {
+			return true
+		}
+	}
+	return false
+}
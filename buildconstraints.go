@@ -0,0 +1,182 @@
+package gotransform
+
+import (
+	"go/ast"
+	"go/build/constraint"
+
+	"github.com/pkg/errors"
+)
+
+// RewriteBuildConstraint applies a function to the build constraint of every file. The
+// constraint is read from the //go:build line or, if there is none, from the // +build
+// lines of the file. The function is called with nil for files without a constraint and
+// may return nil to remove the constraint altogether. The constraint is always written
+// out as a //go:build line; // +build lines are kept in sync if the file had any.
+func RewriteBuildConstraint(rewrite func(constraint.Expr) constraint.Expr) FileTransformation {
+	return &genericTransformation{
+		apply: func(context FileContext) error {
+			expr, hasPlusBuild, err := extractBuildConstraint(context.File)
+			if err != nil {
+				return errors.Wrapf(err, "RewriteBuildConstraint: Invalid build constraint in %s", context.RelativePath)
+			}
+			setBuildConstraint(context.File, rewrite(expr), hasPlusBuild)
+			return nil
+		},
+	}
+}
+
+// AddBuildConstraint ANDs the given constraint to the build constraint of every file,
+// for example
+//     AddBuildConstraint("!release")
+// makes all files excluded from release builds.
+func AddBuildConstraint(expr string) FileTransformation {
+	added, parseErr := constraint.Parse("//go:build " + expr)
+	if parseErr != nil {
+		return failingTransformation(errors.Wrapf(parseErr, "AddBuildConstraint: Invalid constraint %s", expr))
+	}
+	return RewriteBuildConstraint(func(existing constraint.Expr) constraint.Expr {
+		if existing == nil {
+			return added
+		}
+		return &constraint.AndExpr{X: existing, Y: added}
+	})
+}
+
+// RemoveBuildTag removes all occurrences of a tag from the build constraint of every
+// file, for example
+//     RemoveBuildTag("ignore")
+// turns "//go:build ignore && linux" into "//go:build linux" and removes "//go:build ignore"
+// altogether.
+func RemoveBuildTag(tag string) FileTransformation {
+	return RewriteBuildConstraint(func(existing constraint.Expr) constraint.Expr {
+		return removeBuildTag(existing, tag)
+	})
+}
+
+// removeBuildTag removes all mentions of a tag from a constraint expression, dropping
+// the operators that are left without operand.
+func removeBuildTag(expr constraint.Expr, tag string) constraint.Expr {
+	switch e := expr.(type) {
+	case *constraint.TagExpr:
+		if e.Tag == tag {
+			return nil
+		}
+	case *constraint.NotExpr:
+		x := removeBuildTag(e.X, tag)
+		if x == nil {
+			return nil
+		}
+		return &constraint.NotExpr{X: x}
+	case *constraint.AndExpr:
+		x, y := removeBuildTag(e.X, tag), removeBuildTag(e.Y, tag)
+		if x == nil || y == nil {
+			return nonNil(x, y)
+		}
+		return &constraint.AndExpr{X: x, Y: y}
+	case *constraint.OrExpr:
+		x, y := removeBuildTag(e.X, tag), removeBuildTag(e.Y, tag)
+		if x == nil || y == nil {
+			return nonNil(x, y)
+		}
+		return &constraint.OrExpr{X: x, Y: y}
+	}
+	return expr
+}
+
+func nonNil(x, y constraint.Expr) constraint.Expr {
+	if x != nil {
+		return x
+	}
+	return y
+}
+
+// extractBuildConstraint reads the build constraint from the comments preceding the
+// package clause of a file.
+func extractBuildConstraint(f *ast.File) (expr constraint.Expr, hasPlusBuild bool, err error) {
+	var goBuild, plusBuild constraint.Expr
+	for _, c := range headerComments(f) {
+		switch {
+		case constraint.IsGoBuild(c.Text):
+			if goBuild, err = constraint.Parse(c.Text); err != nil {
+				return nil, false, err
+			}
+		case constraint.IsPlusBuild(c.Text):
+			hasPlusBuild = true
+			line, err := constraint.Parse(c.Text)
+			if err != nil {
+				return nil, false, err
+			}
+			if plusBuild == nil {
+				plusBuild = line
+			} else {
+				plusBuild = &constraint.AndExpr{X: plusBuild, Y: line}
+			}
+		}
+	}
+	if goBuild != nil {
+		return goBuild, hasPlusBuild, nil
+	}
+	return plusBuild, hasPlusBuild, nil
+}
+
+// setBuildConstraint replaces all build constraint comments of a file with the given
+// constraint. The printer takes care of moving the new lines to the top of the file
+// and separating them from the package clause.
+func setBuildConstraint(f *ast.File, expr constraint.Expr, withPlusBuild bool) {
+	pos := f.Package
+	if len(f.Comments) > 0 && f.Comments[0].Pos() < pos {
+		pos = f.Comments[0].Pos()
+	}
+
+	// drop the existing constraint lines
+	comments := f.Comments[:0]
+	for _, group := range f.Comments {
+		if group.Pos() < f.Package {
+			list := group.List[:0]
+			for _, c := range group.List {
+				if !constraint.IsGoBuild(c.Text) && !constraint.IsPlusBuild(c.Text) {
+					list = append(list, c)
+				}
+			}
+			group.List = list
+			if len(list) == 0 {
+				if group == f.Doc {
+					f.Doc = nil
+				}
+				continue
+			}
+		}
+		comments = append(comments, group)
+	}
+	f.Comments = comments
+
+	if expr == nil {
+		return
+	}
+	group := &ast.CommentGroup{
+		List: []*ast.Comment{{Slash: pos, Text: "//go:build " + expr.String()}},
+	}
+	if withPlusBuild {
+		lines, err := constraint.PlusBuildLines(expr)
+		// the expression may be too complex to be expressed in +build lines; the
+		// //go:build line is authoritative anyway, so we just leave them out then.
+		if err == nil {
+			for _, line := range lines {
+				group.List = append(group.List, &ast.Comment{Slash: pos, Text: line})
+			}
+		}
+	}
+	f.Comments = append([]*ast.CommentGroup{group}, f.Comments...)
+}
+
+// headerComments returns all comments that precede the package clause of a file.
+func headerComments(f *ast.File) []*ast.Comment {
+	var result []*ast.Comment
+	for _, group := range f.Comments {
+		if group.Pos() >= f.Package {
+			break
+		}
+		result = append(result, group.List...)
+	}
+	return result
+}
@@ -0,0 +1,70 @@
+package gotransform
+
+import (
+	"go/parser"
+	"go/token"
+	"testing"
+)
+
+func TestBuildConstraints(t *testing.T) {
+	tests := []struct {
+		name           string
+		transformation FileTransformation
+		src            string
+		want           string
+	}{
+		{
+			name:           "add to file without constraint",
+			transformation: AddBuildConstraint("!release"),
+			src:            "package p\n",
+			want:           "//go:build !release\n\npackage p\n",
+		},
+		{
+			name:           "add to existing constraint",
+			transformation: AddBuildConstraint("!release"),
+			src:            "//go:build linux || darwin\n\n// Package p does things.\npackage p\n",
+			want:           "//go:build (linux || darwin) && !release\n\n// Package p does things.\npackage p\n",
+		},
+		{
+			name:           "plus build lines are kept in sync",
+			transformation: AddBuildConstraint("debug"),
+			src:            "// +build linux\n\npackage p\n",
+			want:           "//go:build linux && debug\n// +build linux,debug\n\npackage p\n",
+		},
+		{
+			name:           "remove the only tag",
+			transformation: RemoveBuildTag("ignore"),
+			src:            "//go:build ignore\n\npackage p\n",
+			want:           "package p\n",
+		},
+		{
+			name:           "remove tag from expression",
+			transformation: RemoveBuildTag("ignore"),
+			src:            "//go:build ignore && (linux || !windows)\n\npackage p\n",
+			want:           "//go:build linux || !windows\n\npackage p\n",
+		},
+		{
+			name:           "other comments are kept",
+			transformation: RemoveBuildTag("ignore"),
+			src:            "// Copyright notice.\n\n//go:build ignore\n\npackage p\n",
+			want:           "// Copyright notice.\n\npackage p\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := transformSource(t, "p.go", test.src, test.transformation)
+			checkSource(t, got, test.want)
+		})
+	}
+}
+
+func TestAddBuildConstraintInvalid(t *testing.T) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "p.go", "package p\n", parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := AddBuildConstraint("linux &&").Apply(FileContext{File: file, FileSet: fset}); err == nil {
+		t.Error("Expected an error for an invalid constraint")
+	}
+}
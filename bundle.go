@@ -0,0 +1,324 @@
+package gotransform
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/printer"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// BundleFiles merges the files of a single package into the source of one file. Imports
+// are deduplicated; if two files import different packages under the same name, one of
+// them is renamed. If prefix is not empty, it is prepended to all unexported package-level
+// identifiers, which avoids collisions when the bundle is placed into a package that
+// declares identifiers of the same name. References are resolved using the type information
+// if available (see Options.TypeCheck) and the AST's object resolution otherwise; in the
+// latter case, keys in composite literals are told apart from struct fields by the literal's
+// type where it is declared in the package, and are only prefixed if they resolve to a
+// package-level declaration otherwise. Build constraints of the files are not carried over;
+// like for the bundle tool of golang.org/x/tools, test files and files that build.Default
+// excludes are skipped instead, see BundledFile.
+func BundleFiles(files []FileContext, prefix string) ([]byte, error) {
+	var bundled []FileContext
+	for _, context := range files {
+		if BundledFile(context) {
+			bundled = append(bundled, context)
+		}
+	}
+	files = bundled
+	if len(files) == 0 {
+		return nil, errors.New("BundleFiles: No files to bundle")
+	}
+	packageName := files[0].File.Name.Name
+	for _, context := range files {
+		if context.File.Name.Name != packageName {
+			return nil, errors.Errorf("BundleFiles: Files belong to different packages %s and %s", packageName, context.File.Name.Name)
+		}
+	}
+
+	if len(prefix) > 0 {
+		prefixUnexported(files, prefix)
+	}
+	imports := mergeImports(files)
+
+	var buf bytes.Buffer
+	config := &printer.Config{Mode: printer.UseSpaces | printer.TabIndent, Tabwidth: 8}
+	for _, context := range files {
+		if context.File.Doc != nil {
+			for _, c := range context.File.Doc.List {
+				buf.WriteString(c.Text + "\n")
+			}
+			break
+		}
+	}
+	fmt.Fprintf(&buf, "package %s\n\n", packageName)
+	if len(imports) > 0 {
+		buf.WriteString("import (\n")
+		for _, imp := range imports {
+			if imp.explicit {
+				fmt.Fprintf(&buf, "\t%s %s\n", imp.name, strconv.Quote(imp.path))
+			} else {
+				fmt.Fprintf(&buf, "\t%s\n", strconv.Quote(imp.path))
+			}
+		}
+		buf.WriteString(")\n")
+	}
+	for _, context := range files {
+		for _, decl := range context.File.Decls {
+			if genDecl, ok := decl.(*ast.GenDecl); ok && genDecl.Tok == token.IMPORT {
+				continue
+			}
+			buf.WriteString("\n")
+			node := &printer.CommentedNode{Node: decl, Comments: context.File.Comments}
+			if err := config.Fprint(&buf, context.FileSet, node); err != nil {
+				return nil, errors.Wrapf(err, "BundleFiles: Failed to print declaration in %s", context.RelativePath)
+			}
+			buf.WriteString("\n")
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// BundledFile reports whether BundleFiles includes a file in the bundle, i.e. whether it is
+// not a test file and matches the build constraints of build.Default. Files that cannot be
+// found on disk are only checked for their name.
+func BundledFile(context FileContext) bool {
+	if strings.HasSuffix(context.RelativePath, "_test.go") {
+		return false
+	}
+	path := context.FileSet.Position(context.File.Package).Filename
+	match, err := build.Default.MatchFile(filepath.Dir(path), filepath.Base(path))
+	return err != nil || match
+}
+
+type bundleImport struct {
+	name, path string
+	// explicit is set for imports that need to be written with their name
+	explicit bool
+}
+
+// mergeImports collects the imports of all files. Whenever two files use the same name for
+// different packages, the import of the latter file is renamed, together with all references
+// to it in that file.
+func mergeImports(files []FileContext) []bundleImport {
+	var result []bundleImport
+	seen := make(map[bundleImport]int)
+	pathForName := make(map[string]string)
+	for _, context := range files {
+		renames := make(map[string]string)
+		for _, spec := range context.File.Imports {
+			importPath, err := strconv.Unquote(spec.Path.Value)
+			if err != nil {
+				continue
+			}
+			name := importName(spec)
+			explicit := spec.Name != nil
+			if name != "_" && name != "." {
+				if existing, ok := pathForName[name]; ok && existing != importPath {
+					renamed := name
+					for i := 2; ; i++ {
+						renamed = fmt.Sprintf("%s%d", name, i)
+						if other, taken := pathForName[renamed]; !taken || other == importPath {
+							break
+						}
+					}
+					renames[name] = renamed
+					name = renamed
+					explicit = true
+				}
+				pathForName[name] = importPath
+			}
+			key := bundleImport{name: name, path: importPath}
+			if idx, ok := seen[key]; ok {
+				result[idx].explicit = result[idx].explicit || explicit
+			} else {
+				seen[key] = len(result)
+				result = append(result, bundleImport{name, importPath, explicit})
+			}
+		}
+		if len(renames) > 0 {
+			renamePackageReferences(context.File, renames)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].path < result[j].path })
+	return result
+}
+
+// renamePackageReferences renames all qualified identifiers referring to the given packages.
+func renamePackageReferences(f *ast.File, renames map[string]string) {
+	ast.Inspect(f, func(n ast.Node) bool {
+		sel, ok := n.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		if ident, ok := sel.X.(*ast.Ident); ok && ident.Obj == nil {
+			if renamed, ok := renames[ident.Name]; ok {
+				ident.Name = renamed
+			}
+		}
+		return true
+	})
+}
+
+// prefixUnexported prepends the prefix to all unexported package-level identifiers and
+// their uses in the given files.
+func prefixUnexported(files []FileContext, prefix string) {
+	// collect the unexported package-level declarations of all files
+	declared := make(map[string]bool)
+	typeDecls := make(map[string]*ast.TypeSpec)
+	for _, context := range files {
+		for name, obj := range context.File.Scope.Objects {
+			if !ast.IsExported(name) && name != "_" && name != "init" && name != "main" && obj.Kind != ast.Bad {
+				declared[name] = true
+			}
+			if spec, ok := obj.Decl.(*ast.TypeSpec); ok {
+				typeDecls[name] = spec
+			}
+		}
+	}
+
+	for _, context := range files {
+		var pkgScope *types.Scope
+		if context.Package != nil && context.Info != nil {
+			pkgScope = context.Package.Scope()
+		}
+		fileScope := context.File.Scope
+		skip := map[*ast.Ident]bool{context.File.Name: true}
+		// elidedTypes holds the types of composite literals that omit them inside of others
+		elidedTypes := make(map[*ast.CompositeLit]ast.Expr)
+		ast.Inspect(context.File, func(n ast.Node) bool {
+			switch node := n.(type) {
+			case *ast.SelectorExpr:
+				skip[node.Sel] = true
+			case *ast.FuncDecl:
+				// method names live in the namespace of their receiver type
+				if node.Recv != nil {
+					skip[node.Name] = true
+				}
+			case *ast.CompositeLit:
+				// keys of struct literals refer to fields, the type information tells them
+				// apart from variables used as map keys or indices.
+				if pkgScope != nil {
+					return true
+				}
+				typ := node.Type
+				if typ == nil {
+					typ = elidedTypes[node]
+				}
+				kind, elemTypes := literalKeys(typ, typeDecls)
+				for _, elt := range node.Elts {
+					value := elt
+					if kv, ok := elt.(*ast.KeyValueExpr); ok {
+						value = kv.Value
+						if lit := elidedLiteral(kv.Key); lit != nil && elemTypes[0] != nil {
+							elidedTypes[lit] = elidedType(kv.Key, elemTypes[0])
+						}
+						key, ok := kv.Key.(*ast.Ident)
+						if ok && (kind == fieldKeys || kind == unknownKeys && (key.Obj == nil || key.Obj != fileScope.Lookup(key.Name))) {
+							skip[key] = true
+						}
+					}
+					if lit := elidedLiteral(value); lit != nil && elemTypes[1] != nil {
+						elidedTypes[lit] = elidedType(value, elemTypes[1])
+					}
+				}
+			case *ast.Ident:
+				if !declared[node.Name] || skip[node] {
+					return true
+				}
+				if pkgScope != nil {
+					if obj := context.Info.ObjectOf(node); obj != nil {
+						if obj.Parent() == pkgScope {
+							node.Name = prefix + node.Name
+						}
+						return true
+					}
+				}
+				if node.Obj == nil || node.Obj == fileScope.Lookup(node.Name) {
+					node.Name = prefix + node.Name
+				}
+			}
+			return true
+		})
+	}
+}
+
+type compositeKeys int
+
+const (
+	unknownKeys compositeKeys = iota
+	// fieldKeys are the keys of struct literals
+	fieldKeys
+	// valueKeys are the keys of map, slice and array literals
+	valueKeys
+)
+
+// literalKeys tells what the keys of a composite literal of the given type refer to, looking up
+// types declared in the package by name. For map, slice and array types, it also returns the
+// types of the keys and of the elements, which literals inside of the literal may omit.
+func literalKeys(typ ast.Expr, typeDecls map[string]*ast.TypeSpec) (compositeKeys, [2]ast.Expr) {
+	// limit the depth to be safe from invalid recursive declarations
+	for depth := 0; depth < 10; depth++ {
+		switch t := typ.(type) {
+		case *ast.StructType:
+			return fieldKeys, [2]ast.Expr{}
+		case *ast.MapType:
+			return valueKeys, [2]ast.Expr{t.Key, t.Value}
+		case *ast.ArrayType:
+			return valueKeys, [2]ast.Expr{nil, t.Elt}
+		case *ast.SelectorExpr:
+			// fields of imported types are exported, and thus never prefixed
+			return valueKeys, [2]ast.Expr{}
+		case *ast.ParenExpr:
+			typ = t.X
+		case *ast.IndexExpr:
+			typ = t.X
+		case *ast.IndexListExpr:
+			typ = t.X
+		case *ast.Ident:
+			spec, ok := typeDecls[t.Name]
+			if t.Obj != nil {
+				spec, ok = t.Obj.Decl.(*ast.TypeSpec)
+			}
+			if !ok {
+				return unknownKeys, [2]ast.Expr{}
+			}
+			typ = spec.Type
+		default:
+			return unknownKeys, [2]ast.Expr{}
+		}
+	}
+	return unknownKeys, [2]ast.Expr{}
+}
+
+// elidedLiteral returns the composite literal expr consists of if it omits its type, e.g.
+// {1, 2} or &{1, 2} within a slice literal.
+func elidedLiteral(expr ast.Expr) *ast.CompositeLit {
+	if unary, ok := expr.(*ast.UnaryExpr); ok && unary.Op == token.AND {
+		expr = unary.X
+	}
+	if lit, ok := expr.(*ast.CompositeLit); ok && lit.Type == nil {
+		return lit
+	}
+	return nil
+}
+
+// elidedType returns the type of an elided composite literal given the element type of
+// the enclosing literal.
+func elidedType(expr, elemType ast.Expr) ast.Expr {
+	if _, ok := expr.(*ast.UnaryExpr); ok {
+		if star, ok := elemType.(*ast.StarExpr); ok {
+			return star.X
+		}
+	}
+	return elemType
+}
@@ -0,0 +1,134 @@
+package gotransform
+
+import (
+	"go/build"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"testing"
+)
+
+func TestBundleFiles(t *testing.T) {
+	tests := []struct {
+		name   string
+		files  []string
+		prefix string
+		want   string
+	}{
+		{
+			name: "imports",
+			files: []string{
+				"package p\n\nimport \"fmt\"\n\nfunc A() { fmt.Println() }\n",
+				"package p\n\nimport (\n\t\"fmt\"\n\t\"text/template\"\n)\n\nfunc B() { fmt.Println(template.New) }\n",
+				"package p\n\nimport \"html/template\"\n\nfunc C() { template.New(\"\") }\n",
+			},
+			want: `package p
+
+import (
+	"fmt"
+	template2 "html/template"
+	"text/template"
+)
+
+func A() { fmt.Println() }
+
+func B() { fmt.Println(template.New) }
+
+func C() { template2.New("") }
+`,
+		},
+		{
+			name: "prefix",
+			files: []string{
+				"package p\n\ntype point struct{ x, y int }\n\nfunc (p point) len() int { return p.x }\n\nvar origin = point{x: 0, y: 0}\n",
+				"package p\n\nfunc Origin() int { return origin.len() + helper() }\n\nfunc helper() int { x := 1; return x }\n",
+			},
+			prefix: "pkg_",
+			want: `package p
+
+type pkg_point struct{ x, y int }
+
+func (p pkg_point) len() int { return p.x }
+
+var pkg_origin = pkg_point{x: 0, y: 0}
+
+func Origin() int { return pkg_origin.len() + pkg_helper() }
+
+func pkg_helper() int { x := 1; return x }
+`,
+		},
+		{
+			name: "keys of composite literals",
+			files: []string{
+				"package p\n\nconst (\n\tfirst = iota\n\tsecond\n)\n\nvar names = map[int]string{first: \"first\", second: \"second\"}\n",
+				"package p\n\nvar order = [...]int{second: 1, first: 2}\n\ntype table map[int]entry\n\ntype entry struct{ first int }\n\nvar t = table{first: {first: 1}, second: {first: second}}\n",
+				"package p\n\nimport \"image\"\n\nvar pts = []*image.Point{{X: first}}\n\nvar m = map[int]*entry{first: &entry{first: first}, second: {first: second}}\n",
+			},
+			prefix: "pkg_",
+			want: `package p
+
+import (
+	"image"
+)
+
+const (
+	pkg_first = iota
+	pkg_second
+)
+
+var pkg_names = map[int]string{pkg_first: "first", pkg_second: "second"}
+
+var pkg_order = [...]int{pkg_second: 1, pkg_first: 2}
+
+type pkg_table map[int]pkg_entry
+
+type pkg_entry struct{ first int }
+
+var pkg_t = pkg_table{pkg_first: {first: 1}, pkg_second: {first: pkg_second}}
+
+var pkg_pts = []*image.Point{{X: pkg_first}}
+
+var pkg_m = map[int]*pkg_entry{pkg_first: &pkg_entry{first: pkg_first}, pkg_second: {first: pkg_second}}
+`,
+		},
+	}
+	for _, test := range tests {
+		for _, typeCheck := range []bool{false, true} {
+			name := test.name
+			if typeCheck {
+				name += " with types"
+			}
+			t.Run(name, func(t *testing.T) {
+				got := bundleSource(t, test.files, test.prefix, typeCheck)
+				checkSource(t, got, test.want)
+			})
+		}
+	}
+}
+
+// bundleSource parses the given files of a package and bundles them.
+func bundleSource(t *testing.T, sources []string, prefix string, withTypes bool) string {
+	t.Helper()
+	fset := token.NewFileSet()
+	var files []FileContext
+	for i, src := range sources {
+		name := string(rune('a'+i)) + ".go"
+		file, err := parser.ParseFile(fset, name, src, parser.ParseComments)
+		if err != nil {
+			t.Fatalf("Failed to parse input: %v", err)
+		}
+		files = append(files, FileContext{File: file, FileSet: fset, RelativePath: name})
+	}
+	if withTypes {
+		typeCheck(t.TempDir(), fset, files, &build.Default)
+	}
+	src, err := BundleFiles(files, prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	formatted, err := format.Source(src)
+	if err != nil {
+		t.Fatalf("Invalid bundle: %v\n%s", err, src)
+	}
+	return string(formatted)
+}
@@ -0,0 +1,28 @@
+package gotransform
+
+import (
+	"go/ast"
+	"strings"
+)
+
+// directivePrefix is the prefix of all comment directives understood by gotransform,
+// e.g. //gotransform:strip release
+const directivePrefix = "//gotransform:"
+
+// findDirective looks for a directive of the form //gotransform:name in a comment group
+// and returns the space-separated arguments following it.
+func findDirective(doc *ast.CommentGroup, name string) (args []string, found bool) {
+	if doc == nil {
+		return nil, false
+	}
+	for _, c := range doc.List {
+		if !strings.HasPrefix(c.Text, directivePrefix) {
+			continue
+		}
+		fields := strings.Fields(c.Text[len(directivePrefix):])
+		if len(fields) > 0 && fields[0] == name {
+			return fields[1:], true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,234 @@
+package gotransform
+
+import (
+	"go/ast"
+	"go/token"
+	"strconv"
+
+	"github.com/chasingcarrots/gotransform/tagparser"
+
+	"github.com/pkg/errors"
+)
+
+// StructField describes a struct field whose tags are being rewritten.
+type StructField struct {
+	// StructName is the name of the declared struct type containing the field.
+	StructName string
+	// Name is the name of the field. For embedded fields, this is the name of the type.
+	Name     string
+	Embedded bool
+	Field    *ast.Field
+}
+
+// Exported reports whether the field is exported.
+func (sf StructField) Exported() bool {
+	return ast.IsExported(sf.Name)
+}
+
+// FieldTagRewriter computes the new tags of a struct field from its current tags. The
+// order of the returned tags is the order in which they will be written out.
+type FieldTagRewriter func(field StructField, tags []tagparser.Tag) ([]tagparser.Tag, error)
+
+// FieldTagEditor modifies the tags of a struct field in place. Keys that the editor does
+// not touch keep their order. Use EditFieldTags to turn it into a FieldTagRewriter.
+type FieldTagEditor func(field StructField, tags *tagparser.Tags) error
+
+// EditFieldTags returns a FieldTagRewriter that applies a FieldTagEditor to the tags of
+// each field, e.g.
+//     RewriteFieldTags(EditFieldTags(func(field StructField, tags *tagparser.Tags) error {
+//         tags.Set("db", strings.ToLower(field.Name))
+//         return nil
+//     }))
+func EditFieldTags(edit FieldTagEditor) FieldTagRewriter {
+	return func(field StructField, tags []tagparser.Tag) ([]tagparser.Tag, error) {
+		editable := tagparser.NewTags(tags...)
+		if err := edit(field, editable); err != nil {
+			return nil, err
+		}
+		return editable.List(), nil
+	}
+}
+
+// RewriteFieldTags applies a FieldTagRewriter to the fields of all structs declared at the
+// top level of a file. To only rewrite structs with a specific tag, use the FieldTagRewriter
+// handler from the tagproc/handlers package instead.
+func RewriteFieldTags(rewrite FieldTagRewriter) FileTransformation {
+	return &genericTransformation{
+		apply: func(context FileContext) error {
+			for _, decl := range context.File.Decls {
+				genDecl, ok := decl.(*ast.GenDecl)
+				if !ok || genDecl.Tok != token.TYPE {
+					continue
+				}
+				for _, spec := range genDecl.Specs {
+					typeSpec := spec.(*ast.TypeSpec)
+					struc, ok := typeSpec.Type.(*ast.StructType)
+					if !ok {
+						continue
+					}
+					if err := RewriteStructTags(typeSpec.Name.Name, struc, rewrite); err != nil {
+						return errors.Wrapf(err, "RewriteFieldTags: Failed to rewrite tags of %s", typeSpec.Name.Name)
+					}
+				}
+			}
+			return nil
+		},
+	}
+}
+
+// RewriteStructTags applies a FieldTagRewriter to all fields of a struct. Fields declaring
+// several names at once, such as `X, Y float32`, are split up if the rewriter assigns
+// different tags to them.
+func RewriteStructTags(structName string, struc *ast.StructType, rewrite FieldTagRewriter) error {
+	if struc.Fields == nil {
+		return nil
+	}
+	fields := make([]*ast.Field, 0, len(struc.Fields.List))
+	for _, field := range struc.Fields.List {
+		if len(field.Names) == 0 {
+			name := EmbeddedFieldName(field.Type)
+			tags, err := FieldTags(field)
+			if err != nil {
+				return errors.Wrapf(err, "Field %s", name)
+			}
+			newTags, err := rewrite(StructField{structName, name, true, field}, tags.List())
+			if err != nil {
+				return errors.Wrapf(err, "Field %s", name)
+			}
+			SetFieldTags(field, tagparser.NewTags(newTags...))
+			fields = append(fields, field)
+			continue
+		}
+
+		// rewrite the tags for each of the names separately
+		literals := make([]*ast.BasicLit, len(field.Names))
+		split := false
+		for i, name := range field.Names {
+			tags, err := FieldTags(field)
+			if err != nil {
+				return errors.Wrapf(err, "Field %s", name.Name)
+			}
+			newTags, err := rewrite(StructField{structName, name.Name, false, field}, tags.List())
+			if err != nil {
+				return errors.Wrapf(err, "Field %s", name.Name)
+			}
+			literals[i] = makeFieldTag(field.Tag, tagparser.NewTags(newTags...))
+			split = split || literalValue(literals[i]) != literalValue(literals[0])
+		}
+		if !split {
+			field.Tag = literals[0]
+			fields = append(fields, field)
+			continue
+		}
+		for i, name := range field.Names {
+			split := &ast.Field{Names: []*ast.Ident{name}, Type: field.Type, Tag: literals[i]}
+			// the comments must only be printed once
+			if i == 0 {
+				split.Doc, split.Comment = field.Doc, field.Comment
+			}
+			fields = append(fields, split)
+		}
+	}
+	struc.Fields.List = fields
+	return nil
+}
+
+// AddFieldTag returns a FieldTagRewriter that adds a tag with the given key to all exported,
+// non-embedded fields that do not have this key yet. The value is the field name
+// transformed by the naming convention, followed by the options. For example,
+//     AddFieldTag("json", SnakeCase, "omitempty")
+// tags a field PlayerName with `json:"player_name,omitempty"`.
+func AddFieldTag(key string, naming NamingConvention, options ...string) FieldTagRewriter {
+	return EditFieldTags(func(field StructField, tags *tagparser.Tags) error {
+		if field.Embedded || !field.Exported() {
+			return nil
+		}
+		if _, ok := tags.Get(key); !ok {
+			tags.SetOptions(key, tagparser.Value{Name: naming(field.Name), Flags: options})
+		}
+		return nil
+	})
+}
+
+// RemoveFieldTag returns a FieldTagRewriter that removes all tags with the given key,
+// e.g. RemoveFieldTag("editor") drops all `editor:"..."` tags.
+func RemoveFieldTag(key string) FieldTagRewriter {
+	return EditFieldTags(func(field StructField, tags *tagparser.Tags) error {
+		tags.Delete(key)
+		return nil
+	})
+}
+
+// ChainFieldTagRewriters combines several FieldTagRewriters into one that applies them
+// in order.
+func ChainFieldTagRewriters(rewriters ...FieldTagRewriter) FieldTagRewriter {
+	return func(field StructField, tags []tagparser.Tag) ([]tagparser.Tag, error) {
+		for _, rewrite := range rewriters {
+			var err error
+			if tags, err = rewrite(field, tags); err != nil {
+				return nil, err
+			}
+		}
+		return tags, nil
+	}
+}
+
+// FieldTags parses the tags of a struct field in the AST so that they can be edited; write
+// them back using SetFieldTags. A field without tags yields empty tags.
+func FieldTags(field *ast.Field) (*tagparser.Tags, error) {
+	if field.Tag == nil {
+		return tagparser.NewTags(), nil
+	}
+	literal, err := strconv.Unquote(field.Tag.Value)
+	if err != nil {
+		return nil, errors.Wrapf(err, "FieldTags: Invalid field tag %s", field.Tag.Value)
+	}
+	tags, err := tagparser.ParseTags(literal)
+	if err != nil {
+		return nil, errors.Wrapf(err, "FieldTags: Invalid field tag %s", field.Tag.Value)
+	}
+	return tags, nil
+}
+
+// SetFieldTags replaces the tags of a struct field in the AST. If there are no tags left,
+// the tag is removed from the field.
+func SetFieldTags(field *ast.Field, tags *tagparser.Tags) {
+	field.Tag = makeFieldTag(field.Tag, tags)
+}
+
+// makeFieldTag creates the literal for a field tag, reusing the position of the old one.
+func makeFieldTag(old *ast.BasicLit, tags *tagparser.Tags) *ast.BasicLit {
+	if tags.Len() == 0 {
+		return nil
+	}
+	literal := &ast.BasicLit{Kind: token.STRING, Value: tags.Literal()}
+	if old != nil {
+		literal.ValuePos = old.ValuePos
+	}
+	return literal
+}
+
+func literalValue(literal *ast.BasicLit) string {
+	if literal == nil {
+		return ""
+	}
+	return literal.Value
+}
+
+// EmbeddedFieldName returns the name of an embedded field given its type, i.e. the name of
+// the type without package qualifier, pointer or type arguments.
+func EmbeddedFieldName(typ ast.Expr) string {
+	switch t := typ.(type) {
+	case *ast.StarExpr:
+		return EmbeddedFieldName(t.X)
+	case *ast.SelectorExpr:
+		return t.Sel.Name
+	case *ast.IndexExpr:
+		return EmbeddedFieldName(t.X)
+	case *ast.IndexListExpr:
+		return EmbeddedFieldName(t.X)
+	case *ast.Ident:
+		return t.Name
+	}
+	return ""
+}
@@ -0,0 +1,128 @@
+package gotransform
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/chasingcarrots/gotransform/tagparser"
+
+	"github.com/pkg/errors"
+)
+
+func TestRewriteFieldTags(t *testing.T) {
+	tests := []struct {
+		name    string
+		rewrite FieldTagRewriter
+		src     string
+		want    string
+	}{
+		{
+			name:    "add tags",
+			rewrite: AddFieldTag("json", SnakeCase, "omitempty"),
+			src: "package p\n\ntype T struct {\n" +
+				"\tPlayerName string\n" +
+				"\tScore int `json:\"points\"`\n" +
+				"\thidden bool\n" +
+				"\tEmbedded\n" +
+				"}\n",
+			want: "package p\n\ntype T struct {\n" +
+				"\tPlayerName string `json:\"player_name,omitempty\"`\n" +
+				"\tScore int `json:\"points\"`\n" +
+				"\thidden bool\n" +
+				"\tEmbedded\n" +
+				"}\n",
+		},
+		{
+			name:    "remove tags",
+			rewrite: RemoveFieldTag("editor"),
+			src:     "package p\n\ntype T struct {\n\tA int `editor:\"x\" json:\"a\" editor:\"y\"`\n\tB int `editor:\"z\"`\n}\n",
+			want:    "package p\n\ntype T struct {\n\tA int `json:\"a\"`\n\tB int\n}\n",
+		},
+		{
+			name:    "chain",
+			rewrite: ChainFieldTagRewriters(RemoveFieldTag("json"), AddFieldTag("json", CamelCase)),
+			src:     "package p\n\ntype T struct {\n\tMaxSpeed float32 `json:\"speed\" yaml:\"speed\"`\n}\n",
+			want:    "package p\n\ntype T struct {\n\tMaxSpeed float32 `yaml:\"speed\" json:\"maxSpeed\"`\n}\n",
+		},
+		{
+			name:    "backquotes in values",
+			rewrite: AddFieldTag("json", SnakeCase),
+			src:     "package p\n\ntype T struct {\n\tA int \"doc:\\\"`a`\\\"\"\n}\n",
+			want:    "package p\n\ntype T struct {\n\tA int \"doc:\\\"`a`\\\" json:\\\"a\\\"\"\n}\n",
+		},
+		{
+			name:    "split fields keep their comments once",
+			rewrite: AddFieldTag("json", SnakeCase),
+			src:     "package p\n\ntype T struct {\n\t// Position\n\tX, Y float32 // in meters\n}\n",
+			// the printer places the line comment by its position, i.e. after the last field
+			want: "package p\n\ntype T struct {\n\t// Position\n\tX float32 `json:\"x\"`\n\tY float32 `json:\"y\"` // in meters\n}\n",
+		},
+		{
+			name:    "fields are only split if necessary",
+			rewrite: RemoveFieldTag("json"),
+			src:     "package p\n\ntype T struct {\n\tX, Y float32 `json:\"v\"` // in meters\n}\n",
+			want:    "package p\n\ntype T struct {\n\tX, Y float32 // in meters\n}\n",
+		},
+		{
+			name: "rewrite the list of tags",
+			rewrite: func(field StructField, tags []tagparser.Tag) ([]tagparser.Tag, error) {
+				sort.Slice(tags, func(i, j int) bool { return tags[i].Key < tags[j].Key })
+				return tags, nil
+			},
+			src:  "package p\n\ntype T struct {\n\tA int `yaml:\"a\" json:\"a\" db:\"a\"`\n}\n",
+			want: "package p\n\ntype T struct {\n\tA int `db:\"a\" json:\"a\" yaml:\"a\"`\n}\n",
+		},
+		{
+			name: "edit tags in place",
+			rewrite: EditFieldTags(func(field StructField, tags *tagparser.Tags) error {
+				if field.Embedded {
+					tags.Set("json", ",inline")
+				} else {
+					tags.Set("db", strings.ToLower(field.Name))
+				}
+				return nil
+			}),
+			src:  "package p\n\ntype T struct {\n\tID int `db:\"id\" json:\"id\"`\n\tName string\n\tBase\n}\n",
+			want: "package p\n\ntype T struct {\n\tID int `db:\"id\" json:\"id\"`\n\tName string `db:\"name\"`\n\tBase `json:\",inline\"`\n}\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := transformSource(t, "p.go", test.src, RewriteFieldTags(test.rewrite))
+			checkSource(t, got, test.want)
+		})
+	}
+}
+
+func TestRewriteFieldTagsErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		rewrite FieldTagRewriter
+		src     string
+		err     string
+	}{
+		{
+			name: "editor fails",
+			rewrite: EditFieldTags(func(field StructField, tags *tagparser.Tags) error {
+				return errors.Errorf("No tags allowed on %s.%s", field.StructName, field.Name)
+			}),
+			src: "package p\n\ntype T struct {\n\tA int\n}\n",
+			err: "Failed to rewrite tags of T: Field A: No tags allowed on T.A",
+		},
+		{
+			name:    "invalid tag",
+			rewrite: AddFieldTag("json", SnakeCase),
+			src:     "package p\n\ntype T struct {\n\tA int `json: \"a\"`\n}\n",
+			err:     "Field A: FieldTags: Invalid field tag",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := transformPackage(t, map[string]string{"p.go": test.src}, RewriteFieldTags(test.rewrite))
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("Expected an error containing %q, got %v", test.err, err)
+			}
+		})
+	}
+}
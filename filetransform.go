@@ -3,11 +3,14 @@ package gotransform
 import (
 	"fmt"
 	"go/ast"
+	"go/build"
 	"go/parser"
 	"go/token"
+	"go/types"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 
 	"github.com/pkg/errors"
 )
@@ -16,7 +19,8 @@ import (
 // is applied file-wise. It doesn't have to do anything to the files themselves; it
 // might just as well simply collect information etc.
 type FileTransformation interface {
-	// Prepare is called before any processing takes place.
+	// Prepare is called before any processing takes place: Apply calls it for all
+	// transformations before the first file is transformed.
 	Prepare() error
 	// Apply is where the action happens; called once for every file.
 	Apply(FileContext) error
@@ -31,6 +35,38 @@ type FileContext struct {
 	File         *ast.File
 	FileSet      *token.FileSet
 	RelativePath string
+	// Package and Info contain the type information for the package of the file. They
+	// are only available when type-checking is enabled in the Options, and otherwise nil.
+	// Note that the type information is computed before any transformation runs, so it
+	// does not cover nodes added to the AST by transformations.
+	Package *types.Package
+	Info    *types.Info
+}
+
+// Options control how Apply reads the input files.
+type Options struct {
+	// TypeCheck enables type-checking of all packages in the input path before the
+	// transformations are applied. Type errors do not abort the processing, they just
+	// leave the type information incomplete.
+	TypeCheck bool
+	// BuildContext determines which files are type-checked together, via their build
+	// constraints, and the sizes of types. It defaults to build.Default, i.e. to the
+	// GOOS, GOARCH and build tags of the environment.
+	BuildContext *build.Context
+}
+
+// Validator is implemented by transformations that check the input before any of it is
+// transformed, such as the tag processor with its schemas. Validate is called with all files
+// after every transformation has been prepared and before the first one is applied, so that
+// problems in any file are reported before output is written for the others.
+type Validator interface {
+	Validate(collection []FileContext) error
+}
+
+// writeObserver is implemented by transformations that need to know which files are written
+// while the pipeline runs, such as Manifest.
+type writeObserver interface {
+	fileWritten(path string, result WriteResult)
 }
 
 // Apply recursively walks the file-system, starting at the given input path,
@@ -38,6 +74,32 @@ type FileContext struct {
 // For each file, the transformations are executed in the order that they have in the
 // array.
 func Apply(inputPath string, transformations []FileTransformation) error {
+	return ApplyWithOptions(inputPath, transformations, Options{})
+}
+
+// ApplyWithSummary is like ApplyWithOptions, but also returns a summary of all files written
+// by WriteFileAtomic (and hence by WriteGoFile, WriteTemplate and the writers in the writeout
+// package) while the pipeline ran. If other goroutines write files at the same time, these
+// are included as well.
+func ApplyWithSummary(inputPath string, transformations []FileTransformation, options Options) (WriteSummary, error) {
+	var (
+		summary WriteSummary
+		mutex   sync.Mutex
+	)
+	remove := observeWrites(func(path string, result WriteResult) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		summary.add(path, result)
+	})
+	defer remove()
+	err := ApplyWithOptions(inputPath, transformations, options)
+	mutex.Lock()
+	defer mutex.Unlock()
+	return summary, err
+}
+
+// ApplyWithOptions is like Apply, but allows for further configuration of the processing.
+func ApplyWithOptions(inputPath string, transformations []FileTransformation, options Options) error {
 	// parse the files
 	collection := make([]FileContext, 0)
 	fileset := token.NewFileSet()
@@ -45,6 +107,36 @@ func Apply(inputPath string, transformations []FileTransformation) error {
 	if err := filepath.Walk(inputPath, processFile); err != nil {
 		return errors.Wrapf(err, "Apply FileWalk")
 	}
+	if options.TypeCheck {
+		buildContext := options.BuildContext
+		if buildContext == nil {
+			buildContext = &build.Default
+		}
+		typeCheck(inputPath, fileset, collection, buildContext)
+	}
+
+	// let the transformations observe the files written while the pipeline runs
+	for _, t := range transformations {
+		if observer, ok := t.(writeObserver); ok {
+			defer observeWrites(observer.fileWritten)()
+		}
+	}
+
+	// prepare transformations
+	for _, t := range transformations {
+		if err := t.Prepare(); err != nil {
+			return errors.Wrapf(err, "Apply Prepare")
+		}
+	}
+
+	// validate the input
+	for _, t := range transformations {
+		if validator, ok := t.(Validator); ok {
+			if err := validator.Validate(collection); err != nil {
+				return errors.Wrapf(err, "Apply Validate")
+			}
+		}
+	}
 
 	// apply the transformations
 	for _, t := range transformations {
@@ -87,7 +179,7 @@ func parseFiles(inputPath string, fileSet *token.FileSet, collection *[]FileCont
 }
 
 func readFile(fileset *token.FileSet, inputPath, path string) (*FileContext, error) {
-	file, err := parser.ParseFile(fileset, path, nil, 0)
+	file, err := parser.ParseFile(fileset, path, nil, parser.ParseComments)
 	if err != nil {
 		return nil, errors.Wrapf(err, "Failed to parse file %s", path)
 	}
@@ -97,5 +189,5 @@ func readFile(fileset *token.FileSet, inputPath, path string) (*FileContext, err
 		// this should never yield an error.
 		panic(fmt.Sprintf("Error with filepath.Rel: %v", err))
 	}
-	return &FileContext{file, fileset, relativePath}, nil
+	return &FileContext{File: file, FileSet: fileset, RelativePath: relativePath}, nil
 }
@@ -0,0 +1,52 @@
+package gotransform
+
+import (
+	"reflect"
+	"testing"
+)
+
+// recordingTransformation records the calls of the pipeline.
+type recordingTransformation struct {
+	name  string
+	calls *[]string
+}
+
+func (rt recordingTransformation) Prepare() error {
+	*rt.calls = append(*rt.calls, rt.name+" Prepare")
+	return nil
+}
+
+func (rt recordingTransformation) Apply(context FileContext) error {
+	*rt.calls = append(*rt.calls, rt.name+" Apply "+context.RelativePath)
+	return nil
+}
+
+func (rt recordingTransformation) Finalize() error {
+	*rt.calls = append(*rt.calls, rt.name+" Finalize")
+	return nil
+}
+
+func TestApplyLifecycle(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"a.go": "package p\n",
+		"b.go": "package p\n",
+	})
+	var calls []string
+	transformations := []FileTransformation{
+		recordingTransformation{"first", &calls},
+		recordingTransformation{"second", &calls},
+	}
+	if err := Apply(dir, transformations); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"first Prepare", "second Prepare",
+		"first Apply a.go", "first Apply b.go",
+		"second Apply a.go", "second Apply b.go",
+		"first Finalize", "second Finalize",
+	}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("Expected the calls %q, got %q", want, calls)
+	}
+}
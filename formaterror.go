@@ -0,0 +1,94 @@
+package gotransform
+
+import (
+	"fmt"
+	"go/scanner"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// formatErrorContext is the number of lines shown before and after each offending line.
+const formatErrorContext = 3
+
+// formatErrorMaxLines is the maximum number of offending lines shown in a FormatError.
+const formatErrorMaxLines = 5
+
+// FormatError is returned when generated Go code cannot be formatted, which usually means
+// that it does not parse. Its message contains the offending lines of the code together with
+// some context, so that broken templates can be fixed without looking at the output.
+type FormatError struct {
+	// Path is the path the code was supposed to be written to.
+	Path string
+	// Err is the error reported by the formatter.
+	Err error
+	// Source is the code that failed to format.
+	Source []byte
+}
+
+func (fe *FormatError) Error() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "WriteGoFile: Formatting failed for %s: %v", fe.Path, fe.Err)
+	lines := strings.Split(strings.TrimSuffix(string(fe.Source), "\n"), "\n")
+	errorLines := fe.Lines()
+	if len(errorLines) > formatErrorMaxLines {
+		errorLines = errorLines[:formatErrorMaxLines]
+	}
+	last := 0
+	for _, line := range errorLines {
+		if line < 1 || line > len(lines) {
+			continue
+		}
+		from, to := line-formatErrorContext, line+formatErrorContext
+		if from <= last {
+			from = last + 1
+		} else if last > 0 {
+			sb.WriteString("\n\t...")
+		}
+		if from < 1 {
+			from = 1
+		}
+		if to > len(lines) {
+			to = len(lines)
+		}
+		for i := from; i <= to; i++ {
+			marker := " "
+			if i == line {
+				marker = ">"
+			}
+			fmt.Fprintf(&sb, "\n\t%s%5d| %s", marker, i, lines[i-1])
+		}
+		last = to
+	}
+	return sb.String()
+}
+
+func (fe *FormatError) Unwrap() error { return fe.Err }
+
+var errorLinePattern = regexp.MustCompile(`:(\d+):\d+:`)
+
+// Lines returns the sorted line numbers the formatter complained about.
+func (fe *FormatError) Lines() []int {
+	seen := make(map[int]bool)
+	var list scanner.ErrorList
+	if errors.As(fe.Err, &list) {
+		for _, e := range list {
+			seen[e.Pos.Line] = true
+		}
+	} else {
+		for _, match := range errorLinePattern.FindAllStringSubmatch(fe.Err.Error(), -1) {
+			if line, err := strconv.Atoi(match[1]); err == nil {
+				seen[line] = true
+			}
+		}
+	}
+	lines := make([]int, 0, len(seen))
+	for line := range seen {
+		lines = append(lines, line)
+	}
+	sort.Ints(lines)
+	return lines
+}
@@ -0,0 +1,117 @@
+package gotransform
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+// Formatter formats the content of a file of a certain type, see RegisterFormatter.
+type Formatter func(src []byte) ([]byte, error)
+
+var (
+	formattersMutex sync.RWMutex
+	formatters      = map[string]Formatter{
+		".json": FormatJSON,
+		".txt":  NormalizeWhitespace,
+		".md":   FormatMarkdown,
+	}
+)
+
+// RegisterFormatter registers a formatter for all files with the given extension, e.g.
+// ".json", replacing the formatter registered before. Passing a nil formatter disables the
+// formatting of these files. Formatters are applied by WriteTemplate, and thus by the
+// templaters when they do not format Go code. By default, there are formatters for .json,
+// .txt and .md files. YAML files are not formatted by default, since whitespace within block
+// scalars is significant; if your YAML has none, register NormalizeWhitespace for them.
+func RegisterFormatter(ext string, formatter Formatter) {
+	formattersMutex.Lock()
+	defer formattersMutex.Unlock()
+	if formatter == nil {
+		delete(formatters, strings.ToLower(ext))
+		return
+	}
+	formatters[strings.ToLower(ext)] = formatter
+}
+
+// FormatterFor returns the formatter for the file at the given path, or nil if there is none.
+func FormatterFor(path string) Formatter {
+	formattersMutex.RLock()
+	defer formattersMutex.RUnlock()
+	return formatters[strings.ToLower(filepath.Ext(path))]
+}
+
+// FormatJSON validates JSON and indents it with two spaces. The order of keys is kept.
+func FormatJSON(src []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	trimmed := bytes.TrimSpace(src)
+	if err := json.Indent(&buf, trimmed, "", "  "); err != nil {
+		if syntaxErr, ok := err.(*json.SyntaxError); ok {
+			// the offset refers to the trimmed source, while the line refers to the original
+			leading := len(src) - len(bytes.TrimLeftFunc(src, unicode.IsSpace))
+			line := 1 + bytes.Count(src[:leading+int(syntaxErr.Offset)], []byte("\n"))
+			return nil, errors.Wrapf(err, "FormatJSON: Invalid JSON in line %d", line)
+		}
+		return nil, errors.Wrap(err, "FormatJSON: Invalid JSON")
+	}
+	buf.WriteString("\n")
+	return buf.Bytes(), nil
+}
+
+// NormalizeWhitespace removes trailing whitespace from all lines, collapses consecutive
+// blank lines into one and makes sure that the text ends with exactly one newline.
+func NormalizeWhitespace(src []byte) ([]byte, error) {
+	return normalizeLines(src, false, func(line string, _ bool) string {
+		return strings.TrimRight(line, " \t\r")
+	}), nil
+}
+
+// FormatMarkdown is like NormalizeWhitespace, but keeps the two trailing spaces marking a
+// hard line break and leaves fenced code blocks as they are.
+func FormatMarkdown(src []byte) ([]byte, error) {
+	return normalizeLines(src, true, func(line string, inCode bool) string {
+		if inCode {
+			return strings.TrimRight(line, "\r")
+		}
+		trimmed := strings.TrimRight(line, " \t\r")
+		if len(trimmed) > 0 && strings.HasSuffix(strings.TrimRight(line, "\r"), "  ") {
+			return trimmed + "  "
+		}
+		return trimmed
+	}), nil
+}
+
+// normalizeLines applies a function to every line and collapses blank lines. If fences is set,
+// fenced code blocks are recognized and blank lines in them are kept.
+func normalizeLines(src []byte, fences bool, normalize func(line string, inCode bool) string) []byte {
+	var buf bytes.Buffer
+	lines := strings.Split(string(src), "\n")
+	inCode := false
+	blank := true // suppresses blank lines at the start
+	for _, line := range lines {
+		fence := fences && strings.HasPrefix(strings.TrimSpace(line), "```")
+		line = normalize(line, inCode && !fence)
+		if fence {
+			inCode = !inCode
+		}
+		if len(strings.TrimSpace(line)) == 0 && !inCode {
+			if !blank {
+				buf.WriteString("\n")
+			}
+			blank = true
+			continue
+		}
+		buf.WriteString(line + "\n")
+		blank = false
+	}
+	out := bytes.TrimRight(buf.Bytes(), "\n")
+	if len(out) == 0 {
+		return out
+	}
+	return append(out, '\n')
+}
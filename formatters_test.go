@@ -0,0 +1,80 @@
+package gotransform
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFormatters(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		src  string
+		want string
+		err  string
+	}{
+		{
+			name: "json",
+			path: "a.json",
+			src:  "\n\n{\"b\": [1,2], \"a\": {}}\n\n",
+			want: "{\n  \"b\": [\n    1,\n    2\n  ],\n  \"a\": {}\n}\n",
+		},
+		{
+			name: "json error line",
+			path: "a.json",
+			src:  "\n\n{\n  \"a\": 1,\n  \"b\": x\n}\n",
+			err:  "Invalid JSON in line 5",
+		},
+		{
+			name: "text",
+			path: "a.txt",
+			src:  "\n\na  \n\n\n\tb\t\n\n",
+			want: "a\n\n\tb\n",
+		},
+		{
+			name: "markdown",
+			path: "README.MD",
+			src:  "# Title \n\n\nhard  \nbreak\n\n```go\nfunc f() {\n\n\n}  \n```\n",
+			want: "# Title\n\nhard  \nbreak\n\n```go\nfunc f() {\n\n\n}  \n```\n",
+		},
+		{
+			name: "yaml is left alone",
+			path: "a.yaml",
+			src:  "text: |\n  first  \n\n\n  second\n",
+			want: "text: |\n  first  \n\n\n  second\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := []byte(test.src)
+			var err error
+			if formatter := FormatterFor(test.path); formatter != nil {
+				got, err = formatter(got)
+			}
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Errorf("Expected an error containing %q, got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != test.want {
+				t.Errorf("Expected %q, got %q", test.want, got)
+			}
+		})
+	}
+}
+
+func TestRegisterFormatter(t *testing.T) {
+	defer RegisterFormatter(".yaml", nil)
+	RegisterFormatter(".YAML", NormalizeWhitespace)
+	if FormatterFor("a.yaml") == nil {
+		t.Fatal("Expected a formatter for YAML files")
+	}
+	RegisterFormatter(".yaml", nil)
+	if FormatterFor("a.yaml") != nil {
+		t.Error("Expected the YAML formatter to be removed")
+	}
+}
@@ -0,0 +1,156 @@
+package gotransform
+
+import (
+	"bytes"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/imports"
+)
+
+// GoFormat configures how generated Go code is formatted.
+type GoFormat struct {
+	// LocalPrefix is a comma-separated list of import path prefixes. Imports starting with
+	// one of them are put into a separate group after the third-party imports, like
+	// goimports -local does. goimports only reads the prefix from the global variable
+	// imports.LocalPrefix, so formatting with a local prefix sets that variable while
+	// goimports runs and restores it afterwards. Calls with a local prefix are serialized;
+	// without one, the variable is left alone and whatever value it has applies.
+	LocalPrefix string
+	// FormatOnly only formats the code and sorts its imports, without adding missing imports
+	// or removing unused ones. This avoids scanning GOPATH and the module cache, which can
+	// be slow.
+	FormatOnly bool
+	// Simplify applies the simplifications of gofmt -s.
+	Simplify bool
+	// KnownImports maps package names to import paths. Missing imports of these packages are
+	// added directly, so goimports does not have to search for them. Names declared by the
+	// package itself, in the file or in other files of the same package next to it, are
+	// never taken for packages.
+	KnownImports map[string]string
+}
+
+// localPrefixMutex serializes the calls of goimports with a local prefix, which set the
+// global imports.LocalPrefix.
+var localPrefixMutex sync.Mutex
+
+// formatGo formats Go code according to the configuration.
+func formatGo(filename string, src []byte, config GoFormat) ([]byte, error) {
+	if config.Simplify || len(config.KnownImports) > 0 {
+		// if the code does not parse, goimports below reports the error
+		if prepared, ok := prepareGo(filename, src, config); ok {
+			src = prepared
+		}
+	}
+
+	options := &imports.Options{
+		TabWidth:   8,
+		TabIndent:  true,
+		Comments:   true,
+		Fragment:   true,
+		AllErrors:  true,
+		FormatOnly: config.FormatOnly,
+	}
+	if config.LocalPrefix == "" {
+		return imports.Process(filename, src, options)
+	}
+	localPrefixMutex.Lock()
+	defer localPrefixMutex.Unlock()
+	previousPrefix := imports.LocalPrefix
+	imports.LocalPrefix = config.LocalPrefix
+	defer func() { imports.LocalPrefix = previousPrefix }()
+	return imports.Process(filename, src, options)
+}
+
+// prepareGo applies the simplifications and adds the known imports.
+func prepareGo(filename string, src []byte, config GoFormat) ([]byte, bool) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, filename, src, parser.ParseComments)
+	if err != nil {
+		return nil, false
+	}
+	if config.Simplify {
+		simplify(fset, f)
+	}
+	if len(config.KnownImports) > 0 {
+		imported := make(map[string]bool)
+		for _, spec := range f.Imports {
+			imported[importName(spec)] = true
+		}
+		var missing []string
+		var declared map[string]bool
+		for name := range usedPackageNames(f) {
+			if _, known := config.KnownImports[name]; !known || imported[name] || f.Scope.Lookup(name) != nil {
+				continue
+			}
+			if declared == nil {
+				declared = siblingDeclarations(filename, f.Name.Name)
+			}
+			if !declared[name] {
+				missing = append(missing, name)
+			}
+		}
+		sort.Strings(missing)
+		for _, name := range missing {
+			importPath := config.KnownImports[name]
+			if assumedPackageName(importPath) == name {
+				astutil.AddImport(fset, f, importPath)
+			} else {
+				astutil.AddNamedImport(fset, f, name, importPath)
+			}
+		}
+	}
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, f); err != nil {
+		return nil, false
+	}
+	return buf.Bytes(), true
+}
+
+// siblingDeclarations collects the names of the package-level declarations of all other files
+// of the given package in the directory of the file. Files that cannot be read or parsed are
+// skipped, as are declarations that come after a syntax error.
+func siblingDeclarations(filename, packageName string) map[string]bool {
+	declared := make(map[string]bool)
+	dir := filepath.Dir(filename)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return declared
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".go" || entry.Name() == filepath.Base(filename) {
+			continue
+		}
+		f, _ := parser.ParseFile(token.NewFileSet(), filepath.Join(dir, entry.Name()), nil, parser.SkipObjectResolution)
+		if f == nil || f.Name.Name != packageName {
+			continue
+		}
+		for _, decl := range f.Decls {
+			switch d := decl.(type) {
+			case *ast.FuncDecl:
+				if d.Recv == nil {
+					declared[d.Name.Name] = true
+				}
+			case *ast.GenDecl:
+				for _, spec := range d.Specs {
+					switch s := spec.(type) {
+					case *ast.TypeSpec:
+						declared[s.Name.Name] = true
+					case *ast.ValueSpec:
+						for _, name := range s.Names {
+							declared[name.Name] = true
+						}
+					}
+				}
+			}
+		}
+	}
+	return declared
+}
@@ -0,0 +1,83 @@
+package gotransform
+
+import (
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/tools/imports"
+)
+
+func TestFormatGo(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   GoFormat
+		siblings map[string]string
+		src      string
+		want     string
+	}{
+		{
+			name:   "simplify",
+			config: GoFormat{FormatOnly: true, Simplify: true},
+			src:    "package p\n\nvar _ = []T{T{1}}\nvar _ = s[1:len(s)]\n",
+			want:   "package p\n\nvar _ = []T{{1}}\nvar _ = s[1:]\n",
+		},
+		{
+			name:   "local imports",
+			config: GoFormat{FormatOnly: true, LocalPrefix: "example.com/m"},
+			src:    "package p\n\nimport (\n\t\"example.com/m/a\"\n\t\"fmt\"\n\t\"github.com/x/y\"\n)\n\nvar _, _, _ = a.A, fmt.Sprint, y.Y\n",
+			want:   "package p\n\nimport (\n\t\"fmt\"\n\n\t\"github.com/x/y\"\n\n\t\"example.com/m/a\"\n)\n\nvar _, _, _ = a.A, fmt.Sprint, y.Y\n",
+		},
+		{
+			name:   "known imports",
+			config: GoFormat{FormatOnly: true, KnownImports: map[string]string{"yaml": "gopkg.in/yaml.v3", "v": "example.com/vec", "unused": "example.com/unused"}},
+			src:    "package p\n\nvar _, _ = yaml.Node{}, v.Vec{}\n",
+			want:   "package p\n\nimport (\n\tv \"example.com/vec\"\n\t\"gopkg.in/yaml.v3\"\n)\n\nvar _, _ = yaml.Node{}, v.Vec{}\n",
+		},
+		{
+			name:   "names declared in the package",
+			config: GoFormat{FormatOnly: true, KnownImports: map[string]string{"state": "example.com/state", "config": "example.com/config", "other": "example.com/other"}},
+			siblings: map[string]string{
+				"state.go":  "package p\n\nvar state struct{ X int }\n",
+				"other.go":  "package q\n\nvar other struct{ X int }\n",
+				"broken.go": "package p\n\nvar config struct{ X int }\n\nfunc {\n",
+			},
+			src:  "package p\n\nvar _, _, _ = state.X, config.X, other.X\n",
+			want: "package p\n\nimport \"example.com/other\"\n\nvar _, _, _ = state.X, config.X, other.X\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, test.siblings)
+			got, err := formatGo(filepath.Join(dir, "p.go"), []byte(test.src), test.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != test.want {
+				t.Errorf("Unexpected result.\ngot:\n%s\nwant:\n%s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestFormatGoRestoresLocalPrefix(t *testing.T) {
+	previous := imports.LocalPrefix
+	defer func() { imports.LocalPrefix = previous }()
+	imports.LocalPrefix = "example.com/other"
+	if _, err := formatGo("p.go", []byte("package p\n"), GoFormat{FormatOnly: true, LocalPrefix: "example.com/m"}); err != nil {
+		t.Fatal(err)
+	}
+	if imports.LocalPrefix != "example.com/other" {
+		t.Errorf("Expected imports.LocalPrefix to be restored, got %q", imports.LocalPrefix)
+	}
+	// without a local prefix, the global setting applies
+	src := "package p\n\nimport (\n\t\"example.com/other/a\"\n\t\"fmt\"\n)\n\nvar _, _ = a.A, fmt.Sprint\n"
+	want := "package p\n\nimport (\n\t\"fmt\"\n\n\t\"example.com/other/a\"\n)\n\nvar _, _ = a.A, fmt.Sprint\n"
+	got, err := formatGo("p.go", []byte(src), GoFormat{FormatOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("Unexpected result.\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -3,46 +3,84 @@ package gotransform
 import (
 	"bytes"
 	"io"
-	"os"
 	"text/template"
 
 	"github.com/pkg/errors"
-	"golang.org/x/tools/imports"
 )
 
+// GoWriteOptions control how WriteGoFileWithOptions formats code and deals with code that
+// cannot be formatted.
+type GoWriteOptions struct {
+	// Format configures the formatting of the code.
+	Format GoFormat
+	// Strict prevents writing the file at all if it cannot be formatted. Otherwise, the
+	// unformatted code is written.
+	Strict bool
+	// WriteBroken additionally writes code that cannot be formatted to a side file with the
+	// extension .broken appended to the path, e.g. file.go.broken. Such a file is removed
+	// again once the code can be formatted.
+	WriteBroken bool
+}
+
+// DefaultGoWriteOptions returns the options used by WriteGoFile and all functions built on it,
+// such as WriteGoTemplate and the writers in the writeout package: code that cannot be
+// formatted is still written, and imports are fixed with goimports. To use other options,
+// pass them to the variants of these functions ending in WithOptions.
+func DefaultGoWriteOptions() GoWriteOptions {
+	return GoWriteOptions{}
+}
+
 // WriteGoFile writes the contents of a reader to the given path, formatting it and
-// running go imports on the output.
+// running go imports on the output. The file is written with WriteFileAtomic, so it is
+// left alone if its content does not change. Protected regions of the existing file are
+// kept, see PreserveRegions. If the code cannot be formatted, a
+// *FormatError is returned; see GoWriteOptions for what is written in this case.
 func WriteGoFile(path string, reader io.Reader) error {
+	return WriteGoFileWithOptions(path, reader, DefaultGoWriteOptions())
+}
+
+// WriteGoFileWithOptions is like WriteGoFile, but uses the given options instead of
+// DefaultGoWriteOptions.
+func WriteGoFileWithOptions(path string, reader io.Reader, writeOptions GoWriteOptions) error {
 	buf, ok := reader.(*bytes.Buffer)
 	if !ok {
-		buf := new(bytes.Buffer)
-		buf.ReadFrom(reader)
+		buf = new(bytes.Buffer)
+		if _, err := buf.ReadFrom(reader); err != nil {
+			return errors.Wrapf(err, "WriteGoFile: Failed to read code for %s", path)
+		}
 	}
 
-	options := &imports.Options{
-		TabWidth:   8,
-		TabIndent:  true,
-		Comments:   true,
-		Fragment:   true,
-		AllErrors:  true,
-		FormatOnly: false,
-	}
-	formattedCode, formatErr := imports.Process(path, buf.Bytes(), options)
-	if formatErr != nil {
-		formattedCode = buf.Bytes()
+	code, err := preserveExistingRegions(path, buf.Bytes())
+	if err != nil {
+		return errors.Wrap(err, "WriteGoFile")
 	}
 
-	file, err := os.Create(path)
+	brokenPath := path + ".broken"
+	formattedCode, err := formatGo(path, code, writeOptions.Format)
 	if err != nil {
-		return errors.Wrapf(err, "WriteGoFile: File creation failed for %s", path)
+		formatErr := &FormatError{Path: path, Err: err, Source: code}
+		if writeOptions.WriteBroken {
+			if _, err := WriteFileAtomic(brokenPath, code); err != nil {
+				return errors.Wrapf(err, "WriteGoFile: Write failed for %s", brokenPath)
+			}
+		}
+		if !writeOptions.Strict {
+			if _, err := WriteFileAtomic(path, code); err != nil {
+				return errors.Wrapf(err, "WriteGoFile: Write failed for %s", path)
+			}
+		}
+		return formatErr
 	}
-	defer file.Close()
 
-	_, err = file.Write(formattedCode)
-	if err != nil {
+	if writeOptions.WriteBroken {
+		if err := RemoveFile(brokenPath); err != nil {
+			return errors.Wrap(err, "WriteGoFile")
+		}
+	}
+	if _, err := WriteFileAtomic(path, formattedCode); err != nil {
 		return errors.Wrapf(err, "WriteGoFile: Write failed for %s", path)
 	}
-	return errors.Wrapf(formatErr, "WriteGoFile: Formatting failed for", path)
+	return nil
 }
 
 // WriteGoTemplate applies the given template to the value and writes it out as a Go
@@ -55,21 +93,40 @@ func WriteGoTemplate(path string, tmpl *template.Template, value interface{}) er
 	return WriteGoFile(path, &buf)
 }
 
-func WriteTemplate(path string, tmpl *template.Template, value interface{}) error {
+// WriteGoTemplateWithOptions is like WriteGoTemplate, but uses the given options instead of
+// DefaultGoWriteOptions.
+func WriteGoTemplateWithOptions(path string, tmpl *template.Template, value interface{}, writeOptions GoWriteOptions) error {
 	var buf bytes.Buffer
 	if err := tmpl.Execute(&buf, value); err != nil {
 		return errors.Wrapf(err, "WriteGoTemplate: Failed to write template to %s", path)
 	}
-	return writeFile(path, &buf)
+	return WriteGoFileWithOptions(path, &buf, writeOptions)
 }
 
-func writeFile(path string, src io.Reader) error {
-	file, err := os.Create(path)
+// WriteTemplate applies the given template to the value and writes the result to the
+// given path. Protected regions of the existing file are kept, see PreserveRegions. If a
+// formatter is registered for the extension of the path, it is applied afterwards; if it
+// fails, nothing is written. See RegisterFormatter.
+func WriteTemplate(path string, tmpl *template.Template, value interface{}) error {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, value); err != nil {
+		return errors.Wrapf(err, "WriteGoTemplate: Failed to write template to %s", path)
+	}
+	src, err := preserveExistingRegions(path, buf.Bytes())
 	if err != nil {
-		return errors.Wrapf(err, "writeFile: File creation failed for %s", path)
+		return errors.Wrap(err, "WriteTemplate")
 	}
-	defer file.Close()
+	if formatter := FormatterFor(path); formatter != nil {
+		formatted, err := formatter(src)
+		if err != nil {
+			return errors.Wrapf(err, "WriteTemplate: Formatting failed for %s", path)
+		}
+		src = formatted
+	}
+	return writeFile(path, src)
+}
 
-	_, err = io.Copy(file, src)
+func writeFile(path string, src []byte) error {
+	_, err := WriteFileAtomic(path, src)
 	return errors.Wrapf(err, "writeFile: Write failed for %s", path)
 }
@@ -0,0 +1,95 @@
+package gotransform
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWriteGoFileWithOptions(t *testing.T) {
+	const broken = "package p\n\nfunc f() {\n\tx :=\n}\n"
+	tests := []struct {
+		name    string
+		options GoWriteOptions
+		src     string
+		want    string
+		broken  bool
+		err     string
+	}{
+		{
+			name: "formatted",
+			src:  "package p\nfunc f( ) { }\n",
+			want: "package p\n\nfunc f() {}\n",
+		},
+		{
+			name: "broken code is written",
+			src:  broken,
+			want: broken,
+			err:  ">    5| }",
+		},
+		{
+			name:    "strict",
+			options: GoWriteOptions{Strict: true},
+			src:     broken,
+			err:     "Formatting failed",
+		},
+		{
+			name:    "side file",
+			options: GoWriteOptions{Strict: true, WriteBroken: true},
+			src:     broken,
+			broken:  true,
+			err:     "Formatting failed",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "p.go")
+			err := WriteGoFileWithOptions(path, bytes.NewBufferString(test.src), test.options)
+			if test.err == "" && err != nil {
+				t.Fatal(err)
+			}
+			if test.err != "" {
+				if _, ok := err.(*FormatError); !ok || !strings.Contains(err.Error(), test.err) {
+					t.Errorf("Expected a FormatError containing %q, got %v", test.err, err)
+				}
+			}
+			data, err := os.ReadFile(path)
+			if test.want == "" {
+				if !os.IsNotExist(err) {
+					t.Errorf("Expected no file to be written, got %q", data)
+				}
+			} else if string(data) != test.want {
+				t.Errorf("Expected %q, got %q", test.want, data)
+			}
+			if _, err := os.Stat(path + ".broken"); (err == nil) != test.broken {
+				t.Errorf("Expected side file: %v, got error %v", test.broken, err)
+			}
+		})
+	}
+}
+
+func TestWriteGoFileRemovesSideFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "p.go")
+	options := GoWriteOptions{WriteBroken: true}
+	if err := WriteGoFileWithOptions(path, bytes.NewBufferString("package p\nfunc {\n"), options); err == nil {
+		t.Fatal("Expected a formatting error")
+	}
+	if err := WriteGoFileWithOptions(path, bytes.NewBufferString("package p\n"), options); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path + ".broken"); !os.IsNotExist(err) {
+		t.Errorf("Expected the side file to be removed, got %v", err)
+	}
+}
+
+func TestDefaultGoWriteOptions(t *testing.T) {
+	options := DefaultGoWriteOptions()
+	options.Strict = true
+	options.Format.LocalPrefix = "example.com"
+	if got := DefaultGoWriteOptions(); !reflect.DeepEqual(got, GoWriteOptions{}) {
+		t.Errorf("Changing the returned options must not change the defaults, got %+v", got)
+	}
+}
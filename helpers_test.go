@@ -0,0 +1,135 @@
+package gotransform
+
+import (
+	"bytes"
+	"go/build"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// transformSource parses src as the file at the given path, applies the transformations
+// to it like Apply does, and returns the formatted result.
+func transformSource(t *testing.T, filename, src string, transformations ...FileTransformation) string {
+	t.Helper()
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filename, src, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("Failed to parse input: %v", err)
+	}
+	context := FileContext{File: file, FileSet: fset, RelativePath: filepath.Base(filename)}
+	for _, transformation := range transformations {
+		if err := transformation.Prepare(); err != nil {
+			t.Fatalf("Prepare failed: %v", err)
+		}
+		if err := transformation.Apply(context); err != nil {
+			t.Fatalf("Apply failed: %v", err)
+		}
+		if err := transformation.Finalize(); err != nil {
+			t.Fatalf("Finalize failed: %v", err)
+		}
+	}
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, file); err != nil {
+		t.Fatalf("Failed to print result: %v", err)
+	}
+	return buf.String()
+}
+
+// formatNode prints the file of a context.
+func formatNode(t *testing.T, context FileContext) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := format.Node(&buf, context.FileSet, context.File); err != nil {
+		t.Fatalf("Failed to print result: %v", err)
+	}
+	return buf.String()
+}
+
+// writeFiles creates the given files below dir. The keys are slash-separated paths.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// formatSource formats Go source so that expectations in tests do not depend on details of
+// the layout.
+func formatSource(t *testing.T, src string) string {
+	t.Helper()
+	formatted, err := format.Source([]byte(src))
+	if err != nil {
+		t.Fatalf("Invalid expectation: %v\n%s", err, src)
+	}
+	return string(formatted)
+}
+
+// checkSource compares the result of a transformation with the expected source.
+func checkSource(t *testing.T, got, want string) {
+	t.Helper()
+	if want = formatSource(t, want); strings.TrimSpace(got) != strings.TrimSpace(want) {
+		t.Errorf("Unexpected result.\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+// transformPackage writes the files of a package to a temporary directory, type-checks them
+// and applies the transformations to them like ApplyWithOptions does. It returns the
+// formatted results by file name, or the first error.
+func transformPackage(t *testing.T, files map[string]string, transformations ...FileTransformation) (map[string]string, error) {
+	t.Helper()
+	dir := t.TempDir()
+	writeFiles(t, dir, files)
+	var names []string
+	for name := range files {
+		if strings.HasSuffix(name, ".go") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	fset := token.NewFileSet()
+	var collection []FileContext
+	for _, name := range names {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		file, err := parser.ParseFile(fset, path, files[name], parser.ParseComments)
+		if err != nil {
+			t.Fatalf("Failed to parse input: %v", err)
+		}
+		collection = append(collection, FileContext{File: file, FileSet: fset, RelativePath: name})
+	}
+	typeCheck(dir, fset, collection, &build.Default)
+	for _, transformation := range transformations {
+		if err := transformation.Prepare(); err != nil {
+			return nil, err
+		}
+		if validator, ok := transformation.(Validator); ok {
+			if err := validator.Validate(collection); err != nil {
+				return nil, err
+			}
+		}
+		for _, context := range collection {
+			if err := transformation.Apply(context); err != nil {
+				return nil, err
+			}
+		}
+		if err := transformation.Finalize(); err != nil {
+			return nil, err
+		}
+	}
+	result := make(map[string]string)
+	for _, context := range collection {
+		result[context.RelativePath] = formatNode(t, context)
+	}
+	return result, nil
+}
@@ -0,0 +1,155 @@
+package gotransform
+
+import (
+	"bufio"
+	"go/ast"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// RewriteImports rewrites the import paths of a file according to a set of prefix
+// mappings. This is useful when a package tree is copied to a new location and the
+// imports between sibling packages should follow. For example, the mapping
+//     "github.com/us/game/templates/" -> "github.com/us/game/gen/"
+// turns an import of github.com/us/game/templates/foo into github.com/us/game/gen/foo.
+// A prefix only matches whole path elements and the longest matching prefix wins.
+// Imports without an explicit name are given the name of the old package whenever the new
+// package has a different name, so that all references in the file remain valid. The names
+// are read from the package clauses of the packages; if a package cannot be found, such as
+// the old package of a template tree that is excluded from builds, its name is guessed from
+// its path.
+func RewriteImports(mappings map[string]string) FileTransformation {
+	prefixes := make([]string, 0, len(mappings))
+	normalized := make(map[string]string, len(mappings))
+	for from, to := range mappings {
+		from = strings.TrimSuffix(from, "/")
+		normalized[from] = strings.TrimSuffix(to, "/")
+		prefixes = append(prefixes, from)
+	}
+	// longest prefixes first, so that the most specific mapping wins
+	sort.Slice(prefixes, func(i, j int) bool { return len(prefixes[i]) > len(prefixes[j]) })
+
+	return &genericTransformation{
+		apply: func(context FileContext) error {
+			srcDir := sourceDir(context.FileSet, context.File)
+			for _, spec := range context.File.Imports {
+				oldPath, err := strconv.Unquote(spec.Path.Value)
+				if err != nil {
+					return errors.Wrapf(err, "RewriteImports: Invalid import path %s", spec.Path.Value)
+				}
+				newPath, ok := mapImportPath(oldPath, prefixes, normalized)
+				if !ok || newPath == oldPath {
+					continue
+				}
+				if spec.Name == nil {
+					oldName, _ := resolvedImportName(spec, context.Package, srcDir)
+					newName, ok := lookupPackageName(newPath, srcDir)
+					if !ok {
+						newName = assumedPackageName(newPath)
+					}
+					if oldName != newName {
+						spec.Name = &ast.Ident{Name: oldName, NamePos: spec.Path.Pos()}
+					}
+				}
+				spec.Path.Value = strconv.Quote(newPath)
+			}
+			return nil
+		},
+	}
+}
+
+// mapImportPath applies the first matching prefix mapping to an import path.
+func mapImportPath(importPath string, prefixes []string, mappings map[string]string) (string, bool) {
+	for _, from := range prefixes {
+		if importPath == from {
+			return mappings[from], true
+		}
+		if strings.HasPrefix(importPath, from+"/") {
+			return mappings[from] + importPath[len(from):], true
+		}
+	}
+	return importPath, false
+}
+
+// FindModule looks for the go.mod file governing the given directory and returns
+// the module path declared in it together with the directory containing it. The
+// directory itself does not need to exist yet.
+func FindModule(dir string) (modulePath, moduleRoot string, err error) {
+	dir, err = filepath.Abs(dir)
+	if err != nil {
+		return "", "", errors.Wrapf(err, "FindModule: Failed to resolve %s", dir)
+	}
+	for current := dir; ; {
+		modulePath, err = readModulePath(filepath.Join(current, "go.mod"))
+		if err == nil {
+			return modulePath, current, nil
+		}
+		if !os.IsNotExist(errors.Cause(err)) {
+			return "", "", err
+		}
+		parent := filepath.Dir(current)
+		if parent == current {
+			return "", "", errors.Errorf("FindModule: No go.mod found for %s", dir)
+		}
+		current = parent
+	}
+}
+
+// ImportPathForDir determines the import path of the package in the given directory
+// from the module path in the governing go.mod file.
+func ImportPathForDir(dir string) (string, error) {
+	modulePath, moduleRoot, err := FindModule(dir)
+	if err != nil {
+		return "", err
+	}
+	dir, err = filepath.Abs(dir)
+	if err != nil {
+		return "", errors.Wrapf(err, "ImportPathForDir: Failed to resolve %s", dir)
+	}
+	relativePath, err := filepath.Rel(moduleRoot, dir)
+	if err != nil {
+		return "", errors.Wrapf(err, "ImportPathForDir: %s is not within %s", dir, moduleRoot)
+	}
+	if relativePath == "." {
+		return modulePath, nil
+	}
+	return path.Join(modulePath, filepath.ToSlash(relativePath)), nil
+}
+
+// readModulePath extracts the module path from a go.mod file.
+func readModulePath(goModPath string) (string, error) {
+	file, err := os.Open(goModPath)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		fields := strings.Fields(line)
+		if len(fields) < 2 || fields[0] != "module" {
+			continue
+		}
+		modulePath := strings.TrimSpace(line[len("module"):])
+		if idx := strings.Index(modulePath, "//"); idx >= 0 {
+			modulePath = strings.TrimSpace(modulePath[:idx])
+		}
+		if unquoted, err := strconv.Unquote(modulePath); err == nil {
+			modulePath = unquoted
+		}
+		if len(modulePath) > 0 {
+			return modulePath, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", errors.Wrapf(err, "Failed to read %s", goModPath)
+	}
+	return "", errors.Errorf("No module directive found in %s", goModPath)
+}
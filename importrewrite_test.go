@@ -0,0 +1,107 @@
+package gotransform
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestRewriteImports(t *testing.T) {
+	// the module provides packages whose names differ from their paths
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"go.mod":          "module example.com/m\n",
+		"go-util/util.go": "package util\n",
+		"lib/v2/lib.go":   "package lib\n",
+		"gen/tools/x.go":  "package util\n",
+	})
+
+	tests := []struct {
+		name     string
+		mappings map[string]string
+		src      string
+		want     string
+	}{
+		{
+			name:     "same name",
+			mappings: map[string]string{"example.com/old/": "example.com/new/"},
+			src:      "package p\n\nimport \"example.com/old/foo\"\n\nvar _ = foo.X\n",
+			want:     "package p\n\nimport \"example.com/new/foo\"\n\nvar _ = foo.X\n",
+		},
+		{
+			name:     "longest prefix wins",
+			mappings: map[string]string{"example.com/old": "example.com/a", "example.com/old/foo": "example.com/b/foo"},
+			src:      "package p\n\nimport \"example.com/old/foo/bar\"\n\nvar _ = bar.X\n",
+			want:     "package p\n\nimport \"example.com/b/foo/bar\"\n\nvar _ = bar.X\n",
+		},
+		{
+			name:     "whole path elements only",
+			mappings: map[string]string{"example.com/old": "example.com/new"},
+			src:      "package p\n\nimport \"example.com/older/foo\"\n\nvar _ = foo.X\n",
+			want:     "package p\n\nimport \"example.com/older/foo\"\n\nvar _ = foo.X\n",
+		},
+		{
+			name:     "explicit name is kept",
+			mappings: map[string]string{"example.com/old/foo": "example.com/new/bar"},
+			src:      "package p\n\nimport f \"example.com/old/foo\"\n\nvar _ = f.X\n",
+			want:     "package p\n\nimport f \"example.com/new/bar\"\n\nvar _ = f.X\n",
+		},
+		{
+			name:     "unknown packages are named by their paths",
+			mappings: map[string]string{"example.com/old/foo": "example.com/new/bar"},
+			src:      "package p\n\nimport \"example.com/old/foo\"\n\nvar _ = foo.X\n",
+			want:     "package p\n\nimport foo \"example.com/new/bar\"\n\nvar _ = foo.X\n",
+		},
+		{
+			name:     "unknown old package with a guessed name",
+			mappings: map[string]string{"example.com/old/go-foo": "example.com/new/foo"},
+			src:      "package p\n\nimport \"example.com/old/go-foo\"\n\nvar _ = foo.X\n",
+			want:     "package p\n\nimport \"example.com/new/foo\"\n\nvar _ = foo.X\n",
+		},
+		{
+			name:     "real name of the new package matches",
+			mappings: map[string]string{"example.com/old/util": "example.com/m/gen/tools"},
+			src:      "package p\n\nimport \"example.com/old/util\"\n\nvar _ = util.X\n",
+			want:     "package p\n\nimport \"example.com/m/gen/tools\"\n\nvar _ = util.X\n",
+		},
+		{
+			name:     "real name differs from path",
+			mappings: map[string]string{"example.com/m/go-util": "example.com/m/gen/helpers"},
+			src:      "package p\n\nimport \"example.com/m/go-util\"\n\nvar _ = util.X\n",
+			want:     "package p\n\nimport util \"example.com/m/gen/helpers\"\n\nvar _ = util.X\n",
+		},
+		{
+			name:     "real name matches new path",
+			mappings: map[string]string{"example.com/m/go-util": "example.com/m/gen/util"},
+			src:      "package p\n\nimport \"example.com/m/go-util\"\n\nvar _ = util.X\n",
+			want:     "package p\n\nimport \"example.com/m/gen/util\"\n\nvar _ = util.X\n",
+		},
+		{
+			name:     "major version",
+			mappings: map[string]string{"example.com/m/lib": "example.com/m/gen/lib"},
+			src:      "package p\n\nimport \"example.com/m/lib/v2\"\n\nvar _ = lib.X\n",
+			want:     "package p\n\nimport \"example.com/m/gen/lib/v2\"\n\nvar _ = lib.X\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := transformSource(t, filepath.Join(dir, "p.go"), test.src, RewriteImports(test.mappings))
+			checkSource(t, got, test.want)
+		})
+	}
+}
+
+func TestAssumedPackageName(t *testing.T) {
+	tests := map[string]string{
+		"fmt":                   "fmt",
+		"github.com/foo/bar":    "bar",
+		"gopkg.in/yaml.v3":      "yaml",
+		"example.com/foo/v2":    "foo",
+		"github.com/foo/go-bar": "bar",
+		"github.com/foo/v":      "v",
+	}
+	for importPath, want := range tests {
+		if got := assumedPackageName(importPath); got != want {
+			t.Errorf("assumedPackageName(%q) = %q, want %q", importPath, got, want)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package gotransform
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path"
+	"text/template"
+
+	"github.com/pkg/errors"
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// Function describes a function or method declaration, see Instrument.
+type Function struct {
+	// Package is the name of the package the function is declared in.
+	Package string
+	Name    string
+	// Receiver is the name of the receiver's base type for methods and empty otherwise.
+	Receiver string
+	Decl     *ast.FuncDecl
+}
+
+// FullName returns the qualified name of a function, e.g. Pkg.Func or Pkg.Type.Method.
+func (f Function) FullName() string {
+	if len(f.Receiver) > 0 {
+		return f.Package + "." + f.Receiver + "." + f.Name
+	}
+	return f.Package + "." + f.Name
+}
+
+// FunctionSelector decides which functions are instrumented, see Instrument.
+type FunctionSelector func(Function) bool
+
+// FunctionsNamed selects all functions whose names match the given pattern in the syntax of
+// path.Match. Methods are matched with their receiver type, so "Physics*" selects functions
+// such as PhysicsStep, while "World.*" selects all methods of the World type.
+func FunctionsNamed(pattern string) FunctionSelector {
+	return func(f Function) bool {
+		name := f.Name
+		if len(f.Receiver) > 0 {
+			name = f.Receiver + "." + f.Name
+		}
+		matched, _ := path.Match(pattern, name)
+		return matched
+	}
+}
+
+// MethodsOf selects all methods of the given receiver type, regardless of whether the
+// receiver is a pointer.
+func MethodsOf(receiver string) FunctionSelector {
+	return func(f Function) bool { return f.Receiver == receiver }
+}
+
+// FunctionsWithDirective selects all functions annotated with a directive of the given
+// name, e.g. FunctionsWithDirective("profile") selects
+//     //gotransform:profile
+//     func Expensive() { ... }
+func FunctionsWithDirective(name string) FunctionSelector {
+	return func(f Function) bool {
+		_, found := findDirective(f.Decl.Doc, name)
+		return found
+	}
+}
+
+// Instrument injects code at the entry and exit of all selected functions. Both entry and
+// exit are Go text templates producing a list of statements; they are executed with the
+// Function as their argument. The entry code is inserted at the beginning of the function
+// body, the exit code is wrapped in a deferred function. Either of them may be empty. The
+// given import paths are added to all files containing instrumented functions. For example,
+//     Instrument(FunctionsNamed("*"), `defer profiler.Scope("{{.FullName}}")()`, "",
+//         "github.com/us/game/profiler")
+// sets up a profiler scope in every function and method.
+func Instrument(selector FunctionSelector, entry, exit string, imports ...string) FileTransformation {
+	entryTemplate, err := template.New("entry").Parse(entry)
+	if err != nil {
+		return failingTransformation(errors.Wrap(err, "Instrument: Invalid entry template"))
+	}
+	exitTemplate, err := template.New("exit").Parse(exit)
+	if err != nil {
+		return failingTransformation(errors.Wrap(err, "Instrument: Invalid exit template"))
+	}
+	return &genericTransformation{
+		apply: func(context FileContext) error {
+			instrumented := false
+			for _, decl := range context.File.Decls {
+				funcDecl, ok := decl.(*ast.FuncDecl)
+				if !ok || funcDecl.Body == nil {
+					continue
+				}
+				function := Function{
+					Package:  context.File.Name.Name,
+					Name:     funcDecl.Name.Name,
+					Receiver: receiverTypeName(funcDecl),
+					Decl:     funcDecl,
+				}
+				if !selector(function) {
+					continue
+				}
+				if err := instrumentFunction(function, entryTemplate, exitTemplate); err != nil {
+					return errors.Wrapf(err, "Instrument: Failed to instrument %s", function.FullName())
+				}
+				instrumented = true
+			}
+			if instrumented {
+				for _, importPath := range imports {
+					astutil.AddImport(context.FileSet, context.File, importPath)
+				}
+			}
+			return nil
+		},
+	}
+}
+
+func instrumentFunction(function Function, entryTemplate, exitTemplate *template.Template) error {
+	entry, err := instantiateStatements(entryTemplate, function)
+	if err != nil {
+		return errors.Wrap(err, "Entry code")
+	}
+	exit, err := instantiateStatements(exitTemplate, function)
+	if err != nil {
+		return errors.Wrap(err, "Exit code")
+	}
+	statements := entry
+	if len(exit) > 0 {
+		statements = append(statements, &ast.DeferStmt{
+			Call: &ast.CallExpr{
+				Fun: &ast.FuncLit{
+					Type: &ast.FuncType{Params: &ast.FieldList{}},
+					Body: &ast.BlockStmt{List: exit},
+				},
+			},
+		})
+	}
+	body := function.Decl.Body
+	for _, stmt := range statements {
+		reposition(stmt, body.Lbrace)
+	}
+	body.List = append(statements, body.List...)
+	return nil
+}
+
+// instantiateStatements executes a template and parses the result as a list of statements.
+func instantiateStatements(tmpl *template.Template, function Function) ([]ast.Stmt, error) {
+	var buf bytes.Buffer
+	buf.WriteString("package p\nfunc _() {\n")
+	if err := tmpl.Execute(&buf, function); err != nil {
+		return nil, err
+	}
+	buf.WriteString("\n}\n")
+	file, err := parser.ParseFile(token.NewFileSet(), "", buf.Bytes(), 0)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to parse generated code")
+	}
+	return file.Decls[0].(*ast.FuncDecl).Body.List, nil
+}
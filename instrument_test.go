@@ -0,0 +1,78 @@
+package gotransform
+
+import (
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+func TestInstrument(t *testing.T) {
+	tests := []struct {
+		name           string
+		transformation FileTransformation
+		src            string
+		want           string
+	}{
+		{
+			name:           "entry code",
+			transformation: Instrument(FunctionsNamed("Physics*"), `trace("{{.FullName}}")`, ""),
+			src:            "package game\n\nfunc PhysicsStep() {\n\tstep()\n}\n\nfunc Render() {}\n",
+			want:           "package game\n\nfunc PhysicsStep() {\n\ttrace(\"game.PhysicsStep\")\n\tstep()\n}\n\nfunc Render() {}\n",
+		},
+		{
+			name:           "exit code and imports",
+			transformation: Instrument(MethodsOf("World"), "", `log.Println("{{.Name}}")`, "log"),
+			src:            "package game\n\nfunc (w *World) Update() int {\n\treturn 1\n}\n",
+			want:           "package game\n\nimport \"log\"\n\nfunc (w *World) Update() int {\n\tdefer func() {\n\t\tlog.Println(\"Update\")\n\t}()\n\treturn 1\n}\n",
+		},
+		{
+			name:           "methods by pattern",
+			transformation: Instrument(FunctionsNamed("World.*"), `count()`, ""),
+			src:            "package game\n\nfunc (w World) A() {\n}\n\nfunc (o Other) A() {}\n",
+			want:           "package game\n\nfunc (w World) A() {\n\tcount()\n}\n\nfunc (o Other) A() {}\n",
+		},
+		{
+			name:           "directive",
+			transformation: Instrument(FunctionsWithDirective("profile"), `defer profile("{{.Name}}")()`, ""),
+			src:            "package game\n\n//gotransform:profile\nfunc Expensive() {\n}\n\nfunc Cheap() {}\n",
+			want:           "package game\n\n//gotransform:profile\nfunc Expensive() {\n\tdefer profile(\"Expensive\")()\n}\n\nfunc Cheap() {}\n",
+		},
+		{
+			name:           "no imports without instrumented functions",
+			transformation: Instrument(FunctionsNamed("Missing"), `trace()`, "", "log"),
+			src:            "package game\n\nfunc Render() {}\n",
+			want:           "package game\n\nfunc Render() {}\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := transformSource(t, "p.go", test.src, test.transformation)
+			checkSource(t, got, test.want)
+		})
+	}
+}
+
+func TestInstrumentErrors(t *testing.T) {
+	tests := []struct {
+		name           string
+		transformation FileTransformation
+		err            string
+	}{
+		{"invalid template", Instrument(FunctionsNamed("*"), "{{.Name", ""), "Invalid entry template"},
+		{"invalid code", Instrument(FunctionsNamed("*"), "trace(", ""), "Failed to parse generated code"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fset := token.NewFileSet()
+			file, err := parser.ParseFile(fset, "p.go", "package p\n\nfunc F() {}\n", parser.ParseComments)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = test.transformation.Apply(FileContext{File: file, FileSet: fset})
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("Expected an error containing %q, got %v", test.err, err)
+			}
+		})
+	}
+}
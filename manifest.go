@@ -0,0 +1,148 @@
+package gotransform
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// manifestHeader is the first line of every manifest file.
+const manifestHeader = "# Files generated by gotransform. Do not edit; stale files listed here are deleted."
+
+// Manifest creates a transformation that keeps track of all files written while the pipeline
+// runs, no matter whether they are written by writeout, by the tag handlers or by any other
+// code using WriteFileAtomic. When the transformation is finalized, it deletes all files
+// that were listed in the manifest at the given path by the previous run but have not been
+// written this time, and then saves the new list. Files that were not created by the pipeline
+// are never touched. Paths in the manifest are stored relative to its directory; files
+// outside of that directory are neither recorded nor deleted, and a manifest listing such
+// paths is rejected.
+// Since files are usually written when the transformations are finalized, Manifest has to
+// be the last transformation in the pipeline. The written files are only tracked while the
+// pipeline runs in Apply or ApplyWithOptions.
+func Manifest(path string) FileTransformation {
+	return &manifest{path: path, dir: filepath.Dir(path)}
+}
+
+type manifest struct {
+	path, dir string
+	previous  []string
+	mutex     sync.Mutex
+	// written holds the entries of the files written in the current run; it is nil
+	// before the transformation is prepared.
+	written map[string]bool
+}
+
+func (m *manifest) Prepare() error {
+	previous, err := readManifest(m.path)
+	if err != nil {
+		return errors.Wrapf(err, "Manifest: Failed to read %s", m.path)
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.previous = previous
+	m.written = make(map[string]bool)
+	return nil
+}
+
+func (m *manifest) Apply(FileContext) error { return nil }
+
+func (m *manifest) fileWritten(path string, _ WriteResult) {
+	entry, err := manifestEntry(m.dir, path)
+	if err != nil || !filepath.IsLocal(filepath.FromSlash(entry)) {
+		return
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.written != nil {
+		m.written[entry] = true
+	}
+}
+
+func (m *manifest) Finalize() error {
+	m.mutex.Lock()
+	written := m.written
+	m.written = nil
+	m.mutex.Unlock()
+	if written == nil {
+		return errors.New("Manifest: Finalize called without Prepare")
+	}
+	own, err := manifestEntry(m.dir, m.path)
+	if err != nil {
+		return errors.Wrapf(err, "Manifest: Invalid path %s", m.path)
+	}
+	delete(written, own)
+
+	for _, entry := range m.previous {
+		if written[entry] {
+			continue
+		}
+		stale := filepath.Join(m.dir, filepath.FromSlash(entry))
+		if err := RemoveFile(stale); err != nil {
+			return errors.Wrap(err, "Manifest: Failed to delete stale file")
+		}
+	}
+
+	entries := make([]string, 0, len(written))
+	for entry := range written {
+		entries = append(entries, entry)
+	}
+	sort.Strings(entries)
+	var buf bytes.Buffer
+	buf.WriteString(manifestHeader + "\n")
+	for _, entry := range entries {
+		buf.WriteString(entry + "\n")
+	}
+	_, err = WriteFileAtomic(m.path, buf.Bytes())
+	return errors.Wrap(err, "Manifest: Failed to write manifest")
+}
+
+// manifestEntry converts the path of a written file into an entry of the manifest.
+func manifestEntry(dir, path string) (string, error) {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	relative, err := filepath.Rel(absDir, absPath)
+	if err != nil {
+		return "", err
+	}
+	return filepath.ToSlash(relative), nil
+}
+
+// readManifest reads the entries of a manifest from the current output; a missing manifest
+// has no entries. Entries referring to files outside of the manifest's directory are
+// rejected, as they would be deleted once they become stale.
+func readManifest(manifestPath string) ([]string, error) {
+	data, err := CurrentOutput().ReadFile(manifestPath)
+	if os.IsNotExist(errors.Cause(err)) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var entries []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if !filepath.IsLocal(filepath.FromSlash(line)) {
+			return nil, errors.Errorf("Entry %s is outside of the manifest's directory", line)
+		}
+		entries = append(entries, path.Clean(line))
+	}
+	return entries, scanner.Err()
+}
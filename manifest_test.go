@@ -0,0 +1,104 @@
+package gotransform
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestManifest(t *testing.T) {
+	input := t.TempDir()
+	writeFiles(t, input, map[string]string{"p.go": "package p\n"})
+	root := t.TempDir()
+	output := filepath.Join(root, "out")
+	manifestPath := filepath.Join(output, "gotransform.manifest")
+	writeFiles(t, root, map[string]string{"out/handwritten.go": "x", "outside.txt": "x"})
+
+	tests := []struct {
+		name     string
+		manifest string
+		written  []string
+		err      string
+		want     string
+		exist    []string
+		missing  []string
+	}{
+		{
+			name:    "first run",
+			written: []string{"out/a.go", "out/sub/b.go", "outside.txt"},
+			want:    manifestHeader + "\na.go\nsub/b.go\n",
+			exist:   []string{"out/a.go", "out/sub/b.go", "out/handwritten.go", "outside.txt"},
+		},
+		{
+			name:    "stale files are deleted",
+			written: []string{"out/a.go", "out/c.go"},
+			want:    manifestHeader + "\na.go\nc.go\n",
+			exist:   []string{"out/a.go", "out/c.go", "out/handwritten.go"},
+			missing: []string{"out/sub/b.go"},
+		},
+		{
+			name:     "entries outside of the directory are rejected",
+			manifest: manifestHeader + "\na.go\n../outside.txt\n",
+			err:      "Entry ../outside.txt is outside of the manifest's directory",
+			exist:    []string{"out/a.go", "outside.txt"},
+		},
+		{
+			name:     "absolute entries are rejected",
+			manifest: manifestHeader + "\n" + filepath.ToSlash(filepath.Join(root, "outside.txt")) + "\n",
+			err:      "is outside of the manifest's directory",
+			exist:    []string{"outside.txt"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.manifest != "" {
+				writeFiles(t, output, map[string]string{"gotransform.manifest": test.manifest})
+			}
+			writer := &genericTransformation{finalize: func() error {
+				for _, name := range test.written {
+					if _, err := WriteFileAtomic(filepath.Join(root, filepath.FromSlash(name)), []byte("x")); err != nil {
+						return err
+					}
+				}
+				return nil
+			}}
+			err := Apply(input, []FileTransformation{writer, Manifest(manifestPath)})
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Errorf("Expected an error containing %q, got %v", test.err, err)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			} else if data, _ := os.ReadFile(manifestPath); string(data) != test.want {
+				t.Errorf("Expected manifest %q, got %q", test.want, data)
+			}
+			for _, name := range test.exist {
+				if _, err := os.Stat(filepath.Join(root, name)); err != nil {
+					t.Errorf("Expected %s to exist: %v", name, err)
+				}
+			}
+			for _, name := range test.missing {
+				if _, err := os.Stat(filepath.Join(root, name)); !os.IsNotExist(err) {
+					t.Errorf("Expected %s to be deleted", name)
+				}
+			}
+		})
+	}
+}
+
+func TestManifestFailingRun(t *testing.T) {
+	input := t.TempDir()
+	writeFiles(t, input, map[string]string{"p.go": "package p\n"})
+	failing := &genericTransformation{apply: func(FileContext) error { return errors.New("failure") }}
+	if err := Apply(input, []FileTransformation{Manifest(filepath.Join(t.TempDir(), "m")), failing}); err == nil {
+		t.Fatal("Expected the pipeline to fail")
+	}
+	observerMutex.Lock()
+	defer observerMutex.Unlock()
+	if len(writeObservers) != 0 {
+		t.Errorf("Expected no write observers after the run, got %d", len(writeObservers))
+	}
+}
@@ -0,0 +1,387 @@
+package gotransform
+
+import (
+	"bytes"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// generatedMarker marks the declarations and struct fields that were added by MergeGoFile.
+const generatedMarker = directivePrefix + "generated"
+
+// MergeGoFile merges the generated Go code into the source of an existing file of the same
+// package. Each top-level declaration of the generated code is marked with the directive
+//     //gotransform:generated
+// in its doc comment. When merging again, marked declarations of the existing file are
+// replaced by the generated declarations of the same kind and name, new declarations are
+// appended, and marked declarations that are no longer generated are removed. Unmarked code
+// and all comments outside of marked declarations are left as they are.
+// If the generated code declares a struct type that the existing file declares by hand,
+// the fields of the generated type are merged into the existing struct in the same way,
+// with the marker as a comment on each field. The imports of the generated code are added
+// to the file, but imports that become unused are not removed; WriteGoFileMerged takes care
+// of that. If existing is empty, the result contains just the marked generated code.
+func MergeGoFile(filename string, existing, generated []byte) ([]byte, error) {
+	gfset := token.NewFileSet()
+	g, err := parser.ParseFile(gfset, filename, generated, parser.ParseComments)
+	if err != nil {
+		return nil, errors.Wrap(err, "MergeGoFile: Failed to parse generated code")
+	}
+	if len(bytes.TrimSpace(existing)) == 0 {
+		existing = []byte("package " + g.Name.Name + "\n")
+	}
+	efset := token.NewFileSet()
+	e, err := parser.ParseFile(efset, filename, existing, parser.ParseComments)
+	if err != nil {
+		return nil, errors.Wrap(err, "MergeGoFile: Failed to parse existing file")
+	}
+	if e.Name.Name != g.Name.Name {
+		return nil, errors.Errorf("MergeGoFile: Generated code for package %s cannot be merged into package %s", g.Name.Name, e.Name.Name)
+	}
+
+	m := &merger{
+		existing:       existing,
+		etf:            efset.File(e.Package),
+		generated:      generated,
+		gtf:            gfset.File(g.Package),
+		generatedByKey: make(map[string]ast.Decl),
+	}
+	for _, decl := range g.Decls {
+		if isImportDecl(decl) {
+			continue
+		}
+		key := declKey(decl)
+		if _, ok := m.generatedByKey[key]; ok {
+			return nil, errors.Errorf("MergeGoFile: %s is generated twice", key)
+		}
+		m.generatedByKey[key] = decl
+		m.order = append(m.order, key)
+	}
+	if err := m.mergeDecls(e); err != nil {
+		return nil, errors.Wrap(err, "MergeGoFile")
+	}
+	merged := applyTextEdits(existing, m.edits)
+
+	// add the imports of the generated code
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, filename, merged, parser.ParseComments)
+	if err != nil {
+		return nil, errors.Wrap(err, "MergeGoFile: Failed to parse merged code")
+	}
+	for _, spec := range g.Imports {
+		importPath, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			continue
+		}
+		if spec.Name != nil {
+			astutil.AddNamedImport(fset, f, spec.Name.Name, importPath)
+		} else {
+			astutil.AddImport(fset, f, importPath)
+		}
+	}
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, f); err != nil {
+		return nil, errors.Wrap(err, "MergeGoFile: Failed to print merged code")
+	}
+	return buf.Bytes(), nil
+}
+
+type textEdit struct {
+	start, end  int
+	replacement []byte
+}
+
+// applyTextEdits applies non-overlapping edits to a text. Insertions at the same offset end
+// up in the order of the edits.
+func applyTextEdits(src []byte, edits []textEdit) []byte {
+	order := make([]int, len(edits))
+	for i := range order {
+		order[i] = i
+	}
+	// apply the edits back to front, so that the offsets stay valid
+	sort.Slice(order, func(i, j int) bool {
+		a, b := edits[order[i]], edits[order[j]]
+		if a.start != b.start {
+			return a.start > b.start
+		}
+		return order[i] > order[j]
+	})
+	result := append([]byte(nil), src...)
+	for _, idx := range order {
+		e := edits[idx]
+		result = append(result[:e.start:e.start], append(e.replacement, result[e.end:]...)...)
+	}
+	return result
+}
+
+type merger struct {
+	existing, generated []byte
+	etf, gtf            *token.File
+	// generatedByKey holds the generated top-level declarations, see declKey
+	generatedByKey map[string]ast.Decl
+	order          []string
+	edits          []textEdit
+}
+
+func (m *merger) mergeDecls(e *ast.File) error {
+	used := make(map[string]bool)
+	for _, decl := range e.Decls {
+		if isImportDecl(decl) {
+			continue
+		}
+		key := declKey(decl)
+		generated, isGenerated := m.generatedByKey[key]
+		start, end := m.etf.Offset(declStart(decl)), m.etf.Offset(decl.End())
+		if !hasDirective("generated", declDoc(decl)) {
+			struc := singleStruct(decl)
+			if struc == nil {
+				if isGenerated {
+					return errors.Errorf("%s is declared by hand", key)
+				}
+				continue
+			}
+			var generatedStruct *ast.StructType
+			if isGenerated {
+				if generatedStruct = singleStruct(generated); generatedStruct == nil {
+					return errors.Errorf("%s is declared by hand", key)
+				}
+				used[key] = true
+			}
+			if err := m.mergeFields(key, struc, generatedStruct); err != nil {
+				return err
+			}
+			continue
+		}
+		if !isGenerated {
+			m.edits = append(m.edits, textEdit{start: start, end: lineEnd(m.existing, end)})
+			continue
+		}
+		used[key] = true
+		m.edits = append(m.edits, textEdit{start, end, m.markedDecl(generated)})
+	}
+
+	// append the new declarations
+	end := len(m.existing)
+	for _, key := range m.order {
+		if !used[key] {
+			text := append([]byte("\n"), m.markedDecl(m.generatedByKey[key])...)
+			m.edits = append(m.edits, textEdit{end, end, append(text, '\n')})
+		}
+	}
+	return nil
+}
+
+// mergeFields merges the fields of a generated struct into a struct declared by hand;
+// generated may be nil if the struct is no longer generated.
+func (m *merger) mergeFields(typeKey string, existing, generated *ast.StructType) error {
+	generatedFields := make(map[string]*ast.Field)
+	var order []string
+	if generated != nil {
+		for _, field := range generated.Fields.List {
+			key := fieldKey(field)
+			generatedFields[key] = field
+			order = append(order, key)
+		}
+	}
+	used := make(map[string]bool)
+	for _, field := range existing.Fields.List {
+		key := fieldKey(field)
+		replacement, isGenerated := generatedFields[key]
+		if !hasDirective("generated", field.Doc, field.Comment) {
+			if isGenerated {
+				return errors.Errorf("Field %s of %s is declared by hand", key, typeKey)
+			}
+			continue
+		}
+		start, end := m.etf.Offset(fieldStart(field)), m.etf.Offset(fieldEnd(field))
+		if !isGenerated {
+			m.edits = append(m.edits, textEdit{start: start, end: lineEnd(m.existing, end)})
+			continue
+		}
+		used[key] = true
+		m.edits = append(m.edits, textEdit{start, end, m.markedField(replacement)})
+	}
+	closing := m.etf.Offset(existing.Fields.Closing)
+	// the new fields need a line of their own
+	prefix := ""
+	if !bytes.HasSuffix(bytes.TrimRight(m.existing[:closing], " \t"), []byte("\n")) {
+		prefix = "\n"
+	}
+	for _, key := range order {
+		if !used[key] {
+			text := prefix + string(m.markedField(generatedFields[key])) + "\n"
+			m.edits = append(m.edits, textEdit{closing, closing, []byte(text)})
+		}
+	}
+	return nil
+}
+
+// markedDecl returns the source of a generated declaration with the marker in its doc comment.
+func (m *merger) markedDecl(decl ast.Decl) []byte {
+	doc := declDoc(decl)
+	text := m.generatedText(decl.Pos(), decl.End())
+	if hasDirective("generated", doc) {
+		return append(m.generatedText(doc.Pos(), doc.End()), append([]byte("\n"), text...)...)
+	}
+	var buf bytes.Buffer
+	if doc != nil {
+		buf.Write(m.generatedText(doc.Pos(), doc.End()))
+		buf.WriteString("\n")
+	}
+	buf.WriteString(generatedMarker + "\n")
+	buf.Write(text)
+	return buf.Bytes()
+}
+
+// markedField returns the source of a generated field, marked as generated.
+func (m *merger) markedField(field *ast.Field) []byte {
+	text := m.generatedText(fieldStart(field), fieldEnd(field))
+	switch {
+	case hasDirective("generated", field.Doc, field.Comment):
+		return text
+	case field.Comment == nil:
+		return append(text, []byte(" "+generatedMarker)...)
+	case field.Doc != nil:
+		doc := m.generatedText(field.Doc.Pos(), field.Doc.End())
+		rest := m.generatedText(field.Pos(), fieldEnd(field))
+		return []byte(string(doc) + "\n" + generatedMarker + "\n" + string(rest))
+	}
+	return append([]byte(generatedMarker+"\n"), text...)
+}
+
+func (m *merger) generatedText(start, end token.Pos) []byte {
+	from, to := m.gtf.Offset(start), m.gtf.Offset(end)
+	// limit the capacity, so that appending to the text does not modify the generated code
+	return m.generated[from:to:to]
+}
+
+// lineEnd extends an offset over trailing blanks and the following line break, so that
+// removing a node does not leave an empty line behind.
+func lineEnd(src []byte, offset int) int {
+	end := offset
+	for end < len(src) && (src[end] == ' ' || src[end] == '\t' || src[end] == '\r') {
+		end++
+	}
+	if end < len(src) && src[end] == '\n' {
+		return end + 1
+	}
+	return offset
+}
+
+// declKey identifies a top-level declaration by its kind and names, e.g. "func T.String"
+// or "const A, B".
+func declKey(decl ast.Decl) string {
+	switch d := decl.(type) {
+	case *ast.FuncDecl:
+		if d.Recv != nil {
+			return "func " + receiverTypeName(d) + "." + d.Name.Name
+		}
+		return "func " + d.Name.Name
+	case *ast.GenDecl:
+		var names []string
+		for _, spec := range d.Specs {
+			switch s := spec.(type) {
+			case *ast.TypeSpec:
+				names = append(names, s.Name.Name)
+			case *ast.ValueSpec:
+				for _, name := range s.Names {
+					names = append(names, name.Name)
+				}
+			}
+		}
+		return d.Tok.String() + " " + strings.Join(names, ", ")
+	}
+	return ""
+}
+
+func fieldKey(field *ast.Field) string {
+	if len(field.Names) == 0 {
+		return EmbeddedFieldName(field.Type)
+	}
+	names := make([]string, len(field.Names))
+	for i, name := range field.Names {
+		names[i] = name.Name
+	}
+	return strings.Join(names, ", ")
+}
+
+func declDoc(decl ast.Decl) *ast.CommentGroup {
+	switch d := decl.(type) {
+	case *ast.FuncDecl:
+		return d.Doc
+	case *ast.GenDecl:
+		return d.Doc
+	}
+	return nil
+}
+
+func declStart(decl ast.Decl) token.Pos {
+	if doc := declDoc(decl); doc != nil {
+		return doc.Pos()
+	}
+	return decl.Pos()
+}
+
+func fieldStart(field *ast.Field) token.Pos {
+	if field.Doc != nil {
+		return field.Doc.Pos()
+	}
+	return field.Pos()
+}
+
+func fieldEnd(field *ast.Field) token.Pos {
+	if field.Comment != nil {
+		return field.Comment.End()
+	}
+	return field.End()
+}
+
+func isImportDecl(decl ast.Decl) bool {
+	genDecl, ok := decl.(*ast.GenDecl)
+	return ok && genDecl.Tok == token.IMPORT
+}
+
+// singleStruct returns the struct of a declaration of a single struct type, or nil.
+func singleStruct(decl ast.Decl) *ast.StructType {
+	genDecl, ok := decl.(*ast.GenDecl)
+	if !ok || genDecl.Tok != token.TYPE || len(genDecl.Specs) != 1 {
+		return nil
+	}
+	struc, _ := genDecl.Specs[0].(*ast.TypeSpec).Type.(*ast.StructType)
+	return struc
+}
+
+// WriteGoFileMerged merges the Go code from the reader into the existing file at the given
+// path (see MergeGoFile) and writes the result like WriteGoFile. If there is no such file,
+// the generated code is written with the markers added.
+func WriteGoFileMerged(path string, reader io.Reader) error {
+	return WriteGoFileMergedWithOptions(path, reader, DefaultGoWriteOptions())
+}
+
+// WriteGoFileMergedWithOptions is like WriteGoFileMerged, but uses the given options instead
+// of DefaultGoWriteOptions.
+func WriteGoFileMergedWithOptions(path string, reader io.Reader, writeOptions GoWriteOptions) error {
+	var generated bytes.Buffer
+	if _, err := generated.ReadFrom(reader); err != nil {
+		return errors.Wrapf(err, "WriteGoFileMerged: Failed to read code for %s", path)
+	}
+	existing, err := CurrentOutput().ReadFile(path)
+	if err != nil && !os.IsNotExist(errors.Cause(err)) {
+		return errors.Wrapf(err, "WriteGoFileMerged: Failed to read %s", path)
+	}
+	merged, err := MergeGoFile(path, existing, generated.Bytes())
+	if err != nil {
+		return errors.Wrapf(err, "WriteGoFileMerged: Failed to merge into %s", path)
+	}
+	return WriteGoFileWithOptions(path, bytes.NewBuffer(merged), writeOptions)
+}
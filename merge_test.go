@@ -0,0 +1,114 @@
+package gotransform
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMergeGoFile(t *testing.T) {
+	tests := []struct {
+		name      string
+		existing  string
+		generated string
+		want      string
+		err       string
+	}{
+		{
+			name:      "new file",
+			generated: "package p\n\nimport \"fmt\"\n\n// String is generated.\nfunc (t T) String() string { return fmt.Sprint(t.A) }\n\nconst Max = 3\n",
+			want:      "package p\n\nimport \"fmt\"\n\n// String is generated.\n//\n//gotransform:generated\nfunc (t T) String() string { return fmt.Sprint(t.A) }\n\n//gotransform:generated\nconst Max = 3\n",
+		},
+		{
+			name: "replace, add and remove declarations",
+			existing: `package p
+
+// T is hand-written.
+type T struct {
+	A int
+}
+
+//gotransform:generated
+func (t T) Old() {}
+
+// Hand is kept.
+func Hand() {}
+
+//gotransform:generated
+const Max = 2
+`,
+			generated: "package p\n\nimport \"strconv\"\n\ntype T struct {\n\tB string\n}\n\nconst Max = 3\n\nfunc (t T) String() string { return strconv.Itoa(t.A) + t.B }\n",
+			want: `package p
+
+import "strconv"
+
+// T is hand-written.
+type T struct {
+	A int
+	B string //gotransform:generated
+}
+
+// Hand is kept.
+func Hand() {}
+
+//gotransform:generated
+const Max = 3
+
+//gotransform:generated
+func (t T) String() string { return strconv.Itoa(t.A) + t.B }
+`,
+		},
+		{
+			name:      "merge fields",
+			existing:  "package p\n\ntype T struct {\n\tA int\n\tB string //gotransform:generated\n\tC bool //gotransform:generated\n}\n",
+			generated: "package p\n\ntype T struct {\n\tC bool\n\tD float64\n}\n",
+			want:      "package p\n\ntype T struct {\n\tA int\n\tC bool    //gotransform:generated\n\tD float64 //gotransform:generated\n}\n",
+		},
+		{
+			name:      "different packages",
+			existing:  "package p\n",
+			generated: "package q\n",
+			err:       "Generated code for package q cannot be merged into package p",
+		},
+		{
+			name:      "duplicate declarations",
+			generated: "package p\n\nfunc F() {}\n\nfunc F() {}\n",
+			err:       "is generated twice",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := MergeGoFile("p.go", []byte(test.existing), []byte(test.generated))
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Errorf("Expected an error containing %q, got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != test.want {
+				t.Errorf("Unexpected result.\ngot:\n%s\nwant:\n%s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestWriteGoFileMerged(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "p.go")
+	writeFiles(t, filepath.Dir(path), map[string]string{"p.go": "package p\n\nfunc Hand() {}\n"})
+	generated := "package p\n\nfunc Gen() {}\n"
+	want := "package p\n\nfunc Hand() {}\n\n//gotransform:generated\nfunc Gen() {}\n"
+	options := GoWriteOptions{Format: GoFormat{FormatOnly: true}}
+	for i := 0; i < 2; i++ {
+		if err := WriteGoFileMergedWithOptions(path, bytes.NewBufferString(generated), options); err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := os.ReadFile(path); string(got) != want {
+			t.Errorf("Run %d: unexpected result.\ngot:\n%s\nwant:\n%s", i+1, got, want)
+		}
+	}
+}
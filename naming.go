@@ -0,0 +1,82 @@
+package gotransform
+
+import (
+	"strings"
+	"unicode"
+)
+
+// NamingConvention turns a Go identifier into a name following some convention, e.g. for
+// keys in serialization formats or for file names.
+type NamingConvention func(name string) string
+
+var (
+	// SnakeCase turns HTTPServerPort into http_server_port.
+	SnakeCase NamingConvention = func(name string) string { return joinWords(name, "_", strings.ToLower) }
+	// KebabCase turns HTTPServerPort into http-server-port.
+	KebabCase NamingConvention = func(name string) string { return joinWords(name, "-", strings.ToLower) }
+	// LowerCase turns HTTPServerPort into httpserverport.
+	LowerCase NamingConvention = strings.ToLower
+	// PascalCase turns http_server_port into HttpServerPort.
+	PascalCase NamingConvention = func(name string) string { return joinWords(name, "", capitalize) }
+	// CamelCase turns HTTPServerPort into httpServerPort.
+	CamelCase NamingConvention = func(name string) string {
+		words := splitWords(name)
+		for i := range words {
+			if i == 0 {
+				words[i] = strings.ToLower(words[i])
+			} else {
+				words[i] = capitalize(words[i])
+			}
+		}
+		return strings.Join(words, "")
+	}
+	// Unchanged keeps names as they are.
+	Unchanged NamingConvention = func(name string) string { return name }
+)
+
+func joinWords(name, separator string, transform func(string) string) string {
+	words := splitWords(name)
+	for i := range words {
+		words[i] = transform(words[i])
+	}
+	return strings.Join(words, separator)
+}
+
+func capitalize(word string) string {
+	if len(word) == 0 {
+		return word
+	}
+	runes := []rune(strings.ToLower(word))
+	runes[0] = unicode.ToUpper(runes[0])
+	return string(runes)
+}
+
+// splitWords splits an identifier into its words. Words are separated by underscores,
+// dashes and changes in case; runs of upper case letters are treated as acronyms, so
+// HTTPServerID yields HTTP, Server, ID.
+func splitWords(name string) []string {
+	var words []string
+	runes := []rune(name)
+	start := 0
+	flush := func(end int) {
+		if end > start {
+			words = append(words, string(runes[start:end]))
+		}
+		start = end
+	}
+	for i, r := range runes {
+		switch {
+		case r == '_' || r == '-':
+			flush(i)
+			start = i + 1
+		case i > start && unicode.IsUpper(r):
+			previous := runes[i-1]
+			nextIsLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(previous) || unicode.IsDigit(previous) || (unicode.IsUpper(previous) && nextIsLower) {
+				flush(i)
+			}
+		}
+	}
+	flush(len(runes))
+	return words
+}
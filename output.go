@@ -0,0 +1,204 @@
+package gotransform
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// WriteResult describes the effect that writing a file had.
+type WriteResult int
+
+const (
+	// FileUnchanged means that the file already had the content that was written.
+	FileUnchanged WriteResult = iota
+	// FileCreated means that the file did not exist before.
+	FileCreated
+	// FileUpdated means that the file existed with a different content.
+	FileUpdated
+)
+
+func (wr WriteResult) String() string {
+	switch wr {
+	case FileUnchanged:
+		return "unchanged"
+	case FileCreated:
+		return "created"
+	case FileUpdated:
+		return "updated"
+	}
+	return fmt.Sprintf("WriteResult(%d)", int(wr))
+}
+
+// WriteSummary lists the paths of all files written while a pipeline ran, grouped by the
+// effect writing them had, see ApplyWithSummary.
+type WriteSummary struct {
+	Created, Updated, Unchanged []string
+}
+
+// String returns a one-line summary such as "2 created, 1 updated, 10 unchanged".
+func (ws WriteSummary) String() string {
+	return fmt.Sprintf("%d created, %d updated, %d unchanged", len(ws.Created), len(ws.Updated), len(ws.Unchanged))
+}
+
+func (ws *WriteSummary) add(path string, result WriteResult) {
+	switch result {
+	case FileCreated:
+		ws.Created = append(ws.Created, path)
+	case FileUpdated:
+		ws.Updated = append(ws.Updated, path)
+	case FileUnchanged:
+		ws.Unchanged = append(ws.Unchanged, path)
+	}
+}
+
+var (
+	observerMutex  sync.Mutex
+	writeObservers = make(map[int]func(path string, result WriteResult))
+	nextObserverID int
+)
+
+// observeWrites registers a function that is called with the path of every file written by
+// WriteFileAtomic. The returned function unregisters it; callers should defer it, so that
+// the observer does not outlive the pipeline run it belongs to.
+func observeWrites(observer func(path string, result WriteResult)) (remove func()) {
+	observerMutex.Lock()
+	defer observerMutex.Unlock()
+	id := nextObserverID
+	nextObserverID++
+	writeObservers[id] = observer
+	return func() {
+		observerMutex.Lock()
+		defer observerMutex.Unlock()
+		delete(writeObservers, id)
+	}
+}
+
+func recordWrite(path string, result WriteResult) {
+	observerMutex.Lock()
+	defer observerMutex.Unlock()
+	for _, observer := range writeObservers {
+		observer(path, result)
+	}
+}
+
+// Output is a backend that generated files are written to. All files written by gotransform
+// go through the current output, see SetOutput.
+type Output interface {
+	// ReadFile returns the content of a previously written file. If there is no such file,
+	// the error satisfies os.IsNotExist.
+	ReadFile(path string) ([]byte, error)
+	// WriteFile replaces the content of a file, creating it if necessary.
+	WriteFile(path string, data []byte) error
+	// Remove deletes a file. If there is no such file, the error satisfies os.IsNotExist.
+	Remove(path string) error
+}
+
+// FileSystem is the default output, which writes to the file system. Missing directories are
+// created, and files are replaced atomically: the data is written to a temporary file in the
+// same directory, which is then renamed, so a file never ends up half-written.
+var FileSystem Output = fileSystem{}
+
+var (
+	outputMutex sync.RWMutex
+	output      = FileSystem
+)
+
+// SetOutput sets the output that all files are written to, e.g. an ArchiveOutput.
+func SetOutput(o Output) {
+	outputMutex.Lock()
+	defer outputMutex.Unlock()
+	output = o
+}
+
+// CurrentOutput returns the output that all files are written to.
+func CurrentOutput() Output {
+	outputMutex.RLock()
+	defer outputMutex.RUnlock()
+	return output
+}
+
+// WriteFileAtomic writes the data to the given path of the current output (see SetOutput).
+// If the file already has exactly this content, it is not touched at all, so its modification
+// time stays the same and build tools do not consider it changed. With the default output,
+// the file is replaced atomically, see FileSystem. The result is recorded in the summary of
+// the pipelines running, see ApplyWithSummary.
+func WriteFileAtomic(path string, data []byte) (WriteResult, error) {
+	out := CurrentOutput()
+	result := FileUpdated
+	existing, err := out.ReadFile(path)
+	if os.IsNotExist(errors.Cause(err)) {
+		result = FileCreated
+	} else if err != nil {
+		return result, errors.Wrapf(err, "WriteFileAtomic: Failed to read existing file %s", path)
+	} else if bytes.Equal(existing, data) {
+		recordWrite(path, FileUnchanged)
+		return FileUnchanged, nil
+	}
+
+	if err := out.WriteFile(path, data); err != nil {
+		return result, errors.Wrapf(err, "WriteFileAtomic: Failed to write %s", path)
+	}
+	recordWrite(path, result)
+	return result, nil
+}
+
+// RemoveFile removes a file from the current output. It is not an error if there is no
+// such file.
+func RemoveFile(path string) error {
+	if err := CurrentOutput().Remove(path); err != nil && !os.IsNotExist(errors.Cause(err)) {
+		return errors.Wrapf(err, "RemoveFile: Failed to remove %s", path)
+	}
+	return nil
+}
+
+type fileSystem struct{}
+
+func (fileSystem) ReadFile(path string) ([]byte, error) {
+	return os.ReadFile(path)
+}
+
+func (fileSystem) Remove(path string) error {
+	return os.Remove(path)
+}
+
+func (fileSystem) WriteFile(path string, data []byte) error {
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+	return writeTempAndRename(path, data, mode)
+}
+
+func writeTempAndRename(path string, data []byte, mode os.FileMode) (err error) {
+	temp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			temp.Close()
+			os.Remove(temp.Name())
+		}
+	}()
+	if _, err = temp.Write(data); err != nil {
+		return err
+	}
+	if err = temp.Sync(); err != nil {
+		return err
+	}
+	if err = temp.Chmod(mode); err != nil {
+		return err
+	}
+	if err = temp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(temp.Name(), path)
+}
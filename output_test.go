@@ -0,0 +1,99 @@
+package gotransform
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sub", "a.txt")
+	tests := []struct {
+		data string
+		want WriteResult
+	}{
+		{"a", FileCreated},
+		{"a", FileUnchanged},
+		{"b", FileUpdated},
+	}
+	for _, test := range tests {
+		result, err := WriteFileAtomic(path, []byte(test.data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result != test.want {
+			t.Errorf("Writing %q: expected %v, got %v", test.data, test.want, result)
+		}
+		if data, _ := os.ReadFile(path); string(data) != test.data {
+			t.Errorf("Expected content %q, got %q", test.data, data)
+		}
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 1 {
+		t.Errorf("Expected no temporary files to remain, got %d files", len(entries))
+	}
+}
+
+func TestApplyWithSummary(t *testing.T) {
+	input := t.TempDir()
+	output := t.TempDir()
+	writeFiles(t, input, map[string]string{"p.go": "package p\n"})
+	writeFiles(t, output, map[string]string{"unchanged.txt": "x", "updated.txt": "old"})
+	write := func(name, data string) error {
+		_, err := WriteFileAtomic(filepath.Join(output, name), []byte(data))
+		return err
+	}
+
+	tests := []struct {
+		name     string
+		finalize func() error
+		want     WriteSummary
+		err      bool
+	}{
+		{
+			name: "files written by the pipeline",
+			finalize: func() error {
+				if err := write("unchanged.txt", "x"); err != nil {
+					return err
+				}
+				if err := write("updated.txt", "new"); err != nil {
+					return err
+				}
+				return write("created.txt", "x")
+			},
+			want: WriteSummary{
+				Created:   []string{filepath.Join(output, "created.txt")},
+				Updated:   []string{filepath.Join(output, "updated.txt")},
+				Unchanged: []string{filepath.Join(output, "unchanged.txt")},
+			},
+		},
+		{
+			name: "failing pipeline",
+			finalize: func() error {
+				if err := write("failed.txt", "x"); err != nil {
+					return err
+				}
+				return errors.New("failure")
+			},
+			want: WriteSummary{Created: []string{filepath.Join(output, "failed.txt")}},
+			err:  true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			summary, err := ApplyWithSummary(input, []FileTransformation{&genericTransformation{finalize: test.finalize}}, Options{})
+			if (err != nil) != test.err {
+				t.Errorf("Unexpected error: %v", err)
+			}
+			// files written after the run are not part of its summary
+			if err := write("after.txt", "x"); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(summary, test.want) {
+				t.Errorf("Expected summary %+v, got %+v", test.want, summary)
+			}
+		})
+	}
+}
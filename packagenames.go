@@ -0,0 +1,107 @@
+package gotransform
+
+import (
+	"go/ast"
+	"go/build"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// packageNameCache caches the results of lookupPackageName, including failures. Package
+// names do not change while the process runs, so the cache does not need to be scoped.
+var packageNameCache sync.Map
+
+// lookupPackageName reads the package clause of the package with the given import path,
+// as the go command would find it from the directory srcDir.
+func lookupPackageName(importPath, srcDir string) (string, bool) {
+	key := srcDir + "\x00" + importPath
+	if name, ok := packageNameCache.Load(key); ok {
+		return name.(string), name.(string) != ""
+	}
+	// in module mode, the go command looks up packages relative to the context's directory
+	context := build.Default
+	context.Dir = srcDir
+	name := ""
+	if pkg, err := context.Import(importPath, srcDir, 0); err == nil {
+		name = pkg.Name
+	}
+	packageNameCache.Store(key, name)
+	return name, name != ""
+}
+
+// assumedPackageName guesses the name of a package from its import path the way goimports
+// does: major version suffixes like /v2 are skipped, as is everything after the first dot
+// and a go- prefix, so gopkg.in/yaml.v3 is assumed to be called yaml and
+// example.com/go-foo/v2 foo.
+func assumedPackageName(importPath string) string {
+	base := importPath
+	if idx := strings.LastIndex(base, "/"); idx >= 0 {
+		base = base[idx+1:]
+		if isMajorVersion(base) {
+			rest := importPath[:idx]
+			base = rest[strings.LastIndex(rest, "/")+1:]
+		}
+	}
+	base = strings.TrimPrefix(base, "go-")
+	if idx := strings.IndexFunc(base, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	}); idx >= 0 {
+		base = base[:idx]
+	}
+	return base
+}
+
+func isMajorVersion(elem string) bool {
+	if len(elem) < 2 || elem[0] != 'v' {
+		return false
+	}
+	for _, r := range elem[1:] {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// resolvedImportName returns the name under which an import is referenced in a file. The
+// name of an import without an explicit name is taken from the type information if pkg is
+// given, and otherwise read from the package clause of the imported package. If the package
+// cannot be found, the name is guessed from the path and ok is false.
+func resolvedImportName(spec *ast.ImportSpec, pkg *types.Package, srcDir string) (name string, ok bool) {
+	if spec.Name != nil {
+		return spec.Name.Name, true
+	}
+	importPath, err := strconv.Unquote(spec.Path.Value)
+	if err != nil {
+		return "", false
+	}
+	if pkg != nil {
+		for _, imported := range pkg.Imports() {
+			// the type checker makes up incomplete packages for imports that it failed to
+			// read, guessing their names from the path
+			if imported.Path() == importPath && imported.Complete() {
+				return imported.Name(), true
+			}
+		}
+	}
+	if name, ok := lookupPackageName(importPath, srcDir); ok {
+		return name, true
+	}
+	return assumedPackageName(importPath), false
+}
+
+// sourceDir returns the directory of the file that the given node belongs to, which is
+// where imports of that file are resolved from.
+func sourceDir(fset *token.FileSet, node ast.Node) string {
+	if file := fset.File(node.Pos()); file != nil {
+		if dir, err := filepath.Abs(filepath.Dir(file.Name())); err == nil {
+			return dir
+		}
+	}
+	return ""
+}
@@ -0,0 +1,145 @@
+package gotransform
+
+import (
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	regionBegin = "gotransform:begin"
+	regionEnd   = "gotransform:end"
+)
+
+// commentLeaders are the comment markers that may precede a region marker.
+var commentLeaders = []string{"//", "#", "<!--", "/*", "--", ";"}
+
+// commentTrailers are the comment markers that may follow the name of a region.
+var commentTrailers = []string{"-->", "*/"}
+
+type region struct {
+	name string
+	// begin and end are the indices of the lines with the markers
+	begin, end int
+}
+
+// parseRegionMarker checks whether a line is a region marker such as
+//     // gotransform:begin NAME
+// and returns the kind of the marker (regionBegin or regionEnd) and the name of the region.
+func parseRegionMarker(line string) (kind, name string, ok bool) {
+	line = strings.TrimSpace(line)
+	for _, leader := range commentLeaders {
+		if strings.HasPrefix(line, leader) {
+			line = strings.TrimSpace(line[len(leader):])
+			break
+		}
+	}
+	for _, trailer := range commentTrailers {
+		line = strings.TrimSpace(strings.TrimSuffix(line, trailer))
+	}
+	fields := strings.Fields(line)
+	if len(fields) != 2 || (fields[0] != regionBegin && fields[0] != regionEnd) {
+		return "", "", false
+	}
+	return fields[0], fields[1], true
+}
+
+// findRegions finds the protected regions in the given lines. Regions must not be nested,
+// and each name may only be used once.
+func findRegions(lines []string) ([]region, error) {
+	var regions []region
+	seen := make(map[string]bool)
+	open := -1
+	for i, line := range lines {
+		kind, name, ok := parseRegionMarker(line)
+		if !ok {
+			continue
+		}
+		if kind == regionBegin {
+			if open >= 0 {
+				return nil, errors.Errorf("Line %d: Region %s begins inside of region %s", i+1, name, regions[open].name)
+			}
+			if seen[name] {
+				return nil, errors.Errorf("Line %d: Region %s is declared twice", i+1, name)
+			}
+			seen[name] = true
+			open = len(regions)
+			regions = append(regions, region{name: name, begin: i, end: -1})
+			continue
+		}
+		if open < 0 || regions[open].name != name {
+			return nil, errors.Errorf("Line %d: End of region %s does not match any begin", i+1, name)
+		}
+		regions[open].end = i
+		open = -1
+	}
+	if open >= 0 {
+		return nil, errors.Errorf("Line %d: Region %s is never closed", regions[open].begin+1, regions[open].name)
+	}
+	return regions, nil
+}
+
+// PreserveRegions copies the contents of the protected regions of an existing file into
+// newly generated code. A protected region is enclosed by marker comments:
+//     // gotransform:begin custom
+//     ... code that is kept when the file is generated again ...
+//     // gotransform:end custom
+// The markers may use any of the comment styles //, #, <!-- -->, /* */, -- and ;. The
+// content between the markers in the generated code is only used when the file is generated
+// for the first time or the existing file does not have this region. It is an error if a
+// region of the existing file that contains anything but whitespace no longer exists in the
+// generated code, since its content would be lost.
+func PreserveRegions(generated, existing []byte) ([]byte, error) {
+	existingLines := strings.Split(string(existing), "\n")
+	existingRegions, err := findRegions(existingLines)
+	if err != nil {
+		return nil, errors.Wrap(err, "PreserveRegions: Invalid regions in existing file")
+	}
+	if len(existingRegions) == 0 {
+		return generated, nil
+	}
+	generatedLines := strings.Split(string(generated), "\n")
+	generatedRegions, err := findRegions(generatedLines)
+	if err != nil {
+		return nil, errors.Wrap(err, "PreserveRegions: Invalid regions in generated code")
+	}
+
+	contents := make(map[string][]string)
+	for _, r := range existingRegions {
+		contents[r.name] = existingLines[r.begin+1 : r.end]
+	}
+	var result []string
+	last := 0
+	for _, r := range generatedRegions {
+		content, ok := contents[r.name]
+		if !ok {
+			continue
+		}
+		result = append(result, generatedLines[last:r.begin+1]...)
+		result = append(result, content...)
+		last = r.end
+		delete(contents, r.name)
+	}
+	result = append(result, generatedLines[last:]...)
+
+	for _, r := range existingRegions {
+		if content, ok := contents[r.name]; ok && len(strings.TrimSpace(strings.Join(content, ""))) > 0 {
+			return nil, errors.Errorf("PreserveRegions: Region %s of the existing file is missing from the generated code", r.name)
+		}
+	}
+	return []byte(strings.Join(result, "\n")), nil
+}
+
+// preserveExistingRegions applies PreserveRegions with the current content of the file at
+// the given path in the current output, if there is any.
+func preserveExistingRegions(path string, generated []byte) ([]byte, error) {
+	existing, err := CurrentOutput().ReadFile(path)
+	if os.IsNotExist(errors.Cause(err)) {
+		return generated, nil
+	} else if err != nil {
+		return nil, errors.Wrapf(err, "Failed to read existing file %s", path)
+	}
+	result, err := PreserveRegions(generated, existing)
+	return result, errors.Wrapf(err, "Failed to preserve regions of %s", path)
+}
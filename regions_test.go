@@ -0,0 +1,106 @@
+package gotransform
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPreserveRegions(t *testing.T) {
+	tests := []struct {
+		name      string
+		generated string
+		existing  string
+		want      string
+		err       string
+	}{
+		{
+			name:      "go code",
+			generated: "package p\n\n// gotransform:begin imports\n// gotransform:end imports\n\nfunc F() {\n\t// gotransform:begin body\n\treturn\n\t// gotransform:end body\n}\n",
+			existing:  "package p\n\n// gotransform:begin imports\nimport \"fmt\"\n// gotransform:end imports\n\nfunc Old() {\n\t// gotransform:begin body\n\tfmt.Println()\n\n\tfmt.Println()\n\t// gotransform:end body\n}\n",
+			want:      "package p\n\n// gotransform:begin imports\nimport \"fmt\"\n// gotransform:end imports\n\nfunc F() {\n\t// gotransform:begin body\n\tfmt.Println()\n\n\tfmt.Println()\n\t// gotransform:end body\n}\n",
+		},
+		{
+			name:      "other comment styles",
+			generated: "<!-- gotransform:begin a -->\ndefault\n<!-- gotransform:end a -->\n# gotransform:begin b\n# gotransform:end b\n/* gotransform:begin c */\nnew\n/* gotransform:end c */\n",
+			existing:  "<!-- gotransform:begin a -->\nkept\n<!-- gotransform:end a -->\n# gotransform:begin b\nkept: true\n# gotransform:end b\n",
+			want:      "<!-- gotransform:begin a -->\nkept\n<!-- gotransform:end a -->\n# gotransform:begin b\nkept: true\n# gotransform:end b\n/* gotransform:begin c */\nnew\n/* gotransform:end c */\n",
+		},
+		{
+			name:      "no regions in the existing file",
+			generated: "// gotransform:begin a\nnew\n// gotransform:end a\n",
+			existing:  "old\n",
+			want:      "// gotransform:begin a\nnew\n// gotransform:end a\n",
+		},
+		{
+			name:      "empty region may disappear",
+			generated: "code\n",
+			existing:  "// gotransform:begin a\n\n// gotransform:end a\n",
+			want:      "code\n",
+		},
+		{
+			name:      "region with content must not disappear",
+			generated: "code\n",
+			existing:  "// gotransform:begin a\nedit\n// gotransform:end a\n",
+			err:       "Region a of the existing file is missing from the generated code",
+		},
+		{
+			name:      "nested regions",
+			generated: "code\n",
+			existing:  "// gotransform:begin a\n// gotransform:begin b\n// gotransform:end b\n// gotransform:end a\n",
+			err:       "Line 2: Region b begins inside of region a",
+		},
+		{
+			name:      "unclosed region",
+			generated: "// gotransform:begin a\n",
+			existing:  "// gotransform:begin a\n// gotransform:end a\n",
+			err:       "Line 1: Region a is never closed",
+		},
+		{
+			name:      "mismatched end",
+			generated: "code\n",
+			existing:  "// gotransform:begin a\n// gotransform:end b\n",
+			err:       "Line 2: End of region b does not match any begin",
+		},
+		{
+			name:      "duplicate region",
+			generated: "code\n",
+			existing:  "# gotransform:begin a\n# gotransform:end a\n# gotransform:begin a\n# gotransform:end a\n",
+			err:       "Line 3: Region a is declared twice",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := PreserveRegions([]byte(test.generated), []byte(test.existing))
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Errorf("Expected an error containing %q, got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != test.want {
+				t.Errorf("Unexpected result.\ngot:\n%s\nwant:\n%s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestWriteGoFilePreservesRegions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "p.go")
+	writeFiles(t, filepath.Dir(path), map[string]string{
+		"p.go": "package p\n\nfunc F() int {\n\t// gotransform:begin body\n\tx := 2\n\treturn x\n\t// gotransform:end body\n}\n",
+	})
+	generated := "package p\nfunc F() int {\n// gotransform:begin body\nreturn 1\n// gotransform:end body\n}\nfunc G() {}\n"
+	if err := WriteGoFileWithOptions(path, bytes.NewBufferString(generated), GoWriteOptions{Format: GoFormat{FormatOnly: true}}); err != nil {
+		t.Fatal(err)
+	}
+	want := "package p\n\nfunc F() int {\n\t// gotransform:begin body\n\tx := 2\n\treturn x\n\t// gotransform:end body\n}\nfunc G() {}\n"
+	if got, _ := os.ReadFile(path); string(got) != want {
+		t.Errorf("Unexpected result.\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
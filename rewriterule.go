@@ -0,0 +1,305 @@
+package gotransform
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"reflect"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// RewriteRule applies a rewrite rule in the style of gofmt -r to all files. The rule has
+// the form "pattern -> replacement", where both sides are Go expressions. Single lowercase
+// letters serve as wildcards that match arbitrary subexpressions; a wildcard that occurs
+// more than once has to match identical expressions. For example,
+//     RewriteRule("oldapi.Get(a, b) -> newapi.Lookup(b, a)")
+// swaps the arguments of all calls to oldapi.Get and redirects them to newapi.Lookup.
+// Note that imports are not adjusted; use AddImport or goimports for that.
+func RewriteRule(rule string) FileTransformation {
+	return RewriteRuleWithTypes(rule, nil)
+}
+
+// RewriteRuleWithTypes is like RewriteRule, but additionally constrains the types of the
+// wildcards. The map assigns type strings to wildcards, written as go/types prints them,
+// e.g. "[]int" or "*github.com/foo/bar.Baz". A constrained wildcard only matches expressions
+// with exactly this type, which requires type information (see Options.TypeCheck); without
+// it, constrained wildcards never match.
+func RewriteRuleWithTypes(rule string, wildcardTypes map[string]string) FileTransformation {
+	pattern, replacement, err := parseRewriteRule(rule)
+	if err != nil {
+		return failingTransformation(errors.Wrapf(err, "RewriteRule: Invalid rule %q", rule))
+	}
+	for wildcard := range wildcardTypes {
+		if !isWildcard(wildcard) {
+			return failingTransformation(errors.Errorf("RewriteRule: %q is not a wildcard", wildcard))
+		}
+	}
+	return &genericTransformation{
+		apply: func(context FileContext) error {
+			rw := &rewriter{
+				pattern:       reflect.ValueOf(pattern),
+				replacement:   reflect.ValueOf(replacement),
+				wildcardTypes: wildcardTypes,
+				info:          context.Info,
+			}
+			astutil.Apply(context.File, nil, rw.visit)
+			if rw.err != nil {
+				return errors.Wrapf(rw.err, "RewriteRule: Failed to apply %q at %s", rule, context.FileSet.Position(rw.errPos))
+			}
+			return nil
+		},
+	}
+}
+
+func parseRewriteRule(rule string) (pattern, replacement ast.Expr, err error) {
+	parts := strings.Split(rule, "->")
+	if len(parts) != 2 {
+		return nil, nil, errors.New("Rule must have the form 'pattern -> replacement'")
+	}
+	if pattern, err = parser.ParseExpr(strings.TrimSpace(parts[0])); err != nil {
+		return nil, nil, errors.Wrap(err, "Failed to parse pattern")
+	}
+	if replacement, err = parser.ParseExpr(strings.TrimSpace(parts[1])); err != nil {
+		return nil, nil, errors.Wrap(err, "Failed to parse replacement")
+	}
+	return pattern, replacement, nil
+}
+
+// isWildcard reports whether an identifier is a wildcard, i.e. a single lowercase letter.
+func isWildcard(name string) bool {
+	return len(name) == 1 && unicode.IsLower(rune(name[0]))
+}
+
+type rewriter struct {
+	pattern, replacement reflect.Value
+	wildcardTypes        map[string]string
+	info                 *types.Info
+	// err is the first error that occurred, at errPos; the rewriting stops there.
+	err    error
+	errPos token.Pos
+}
+
+var (
+	identType        = reflect.TypeOf((*ast.Ident)(nil))
+	objectType       = reflect.TypeOf((*ast.Object)(nil))
+	scopeType        = reflect.TypeOf((*ast.Scope)(nil))
+	commentGroupType = reflect.TypeOf((*ast.CommentGroup)(nil))
+	exprType         = reflect.TypeOf((*ast.Expr)(nil)).Elem()
+)
+
+// visit is called bottom-up for every node of the file and replaces all matching expressions.
+func (rw *rewriter) visit(c *astutil.Cursor) bool {
+	expr, ok := c.Node().(ast.Expr)
+	if !ok {
+		return true
+	}
+	bindings := make(map[string]reflect.Value)
+	if !rw.match(bindings, rw.pattern, reflect.ValueOf(expr)) {
+		return true
+	}
+	result, err := rw.instantiate(bindings, expr.Pos())
+	if err != nil {
+		rw.err, rw.errPos = err, expr.Pos()
+		return false
+	}
+	if canReplace(c, result.Type()) {
+		c.Replace(result.Interface().(ast.Node))
+	}
+	return true
+}
+
+// instantiate substitutes the bindings into the replacement. This fails if a wildcard
+// ends up in a position that its binding cannot take, e.g. when a wildcard bound to a
+// call expression is used as the name in a selector expression.
+func (rw *rewriter) instantiate(bindings map[string]reflect.Value, pos token.Pos) (reflect.Value, error) {
+	result, err := rw.substitute(bindings, rw.replacement, pos)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	if result.Kind() == reflect.Interface {
+		result = result.Elem()
+	}
+	return result, nil
+}
+
+// canReplace checks whether the node at the cursor may be replaced by a node of the given
+// type, e.g. the name in a selector expression has to remain an identifier.
+func canReplace(c *astutil.Cursor, typ reflect.Type) bool {
+	field := reflect.ValueOf(c.Parent()).Elem().FieldByName(c.Name())
+	if !field.IsValid() {
+		return false
+	}
+	fieldType := field.Type()
+	if fieldType.Kind() == reflect.Slice {
+		fieldType = fieldType.Elem()
+	}
+	return typ.AssignableTo(fieldType)
+}
+
+// match reports whether the pattern matches the value, recording the values of the
+// wildcards in bindings.
+func (rw *rewriter) match(bindings map[string]reflect.Value, pattern, val reflect.Value) bool {
+	// compare the dynamic values of interfaces such as ast.Expr
+	if pattern.Kind() == reflect.Interface {
+		pattern = pattern.Elem()
+	}
+	if val.Kind() == reflect.Interface {
+		val = val.Elem()
+	}
+	if pattern.IsValid() && pattern.Type() == identType {
+		ident := pattern.Interface().(*ast.Ident)
+		if bindings != nil && isWildcard(ident.Name) && val.IsValid() && val.Type().Implements(exprType) {
+			if bound, ok := bindings[ident.Name]; ok {
+				return rw.match(nil, bound, val)
+			}
+			if !rw.matchesType(ident.Name, val.Interface().(ast.Expr)) {
+				return false
+			}
+			bindings[ident.Name] = val
+			return true
+		}
+	}
+
+	if !pattern.IsValid() || !val.IsValid() {
+		return !pattern.IsValid() && !val.IsValid()
+	}
+	if pattern.Type() != val.Type() {
+		return false
+	}
+
+	switch pattern.Type() {
+	case identType:
+		// identifiers only need to agree in their names
+		return pattern.Interface().(*ast.Ident).Name == val.Interface().(*ast.Ident).Name
+	case objectType, scopeType, commentGroupType, positionType:
+		return true
+	}
+
+	switch pattern.Kind() {
+	case reflect.Slice:
+		if pattern.Len() != val.Len() {
+			return false
+		}
+		for i := 0; i < pattern.Len(); i++ {
+			if !rw.match(bindings, pattern.Index(i), val.Index(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Struct:
+		for i := 0; i < pattern.NumField(); i++ {
+			if !rw.match(bindings, pattern.Field(i), val.Field(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Ptr:
+		if pattern.IsNil() || val.IsNil() {
+			return pattern.IsNil() && val.IsNil()
+		}
+		return rw.match(bindings, pattern.Elem(), val.Elem())
+	}
+	return pattern.Interface() == val.Interface()
+}
+
+// matchesType checks the type constraint of a wildcard against an expression.
+func (rw *rewriter) matchesType(wildcard string, expr ast.Expr) bool {
+	typeString, ok := rw.wildcardTypes[wildcard]
+	if !ok {
+		return true
+	}
+	if rw.info == nil {
+		return false
+	}
+	typ := rw.info.TypeOf(expr)
+	return typ != nil && types.TypeString(typ, nil) == typeString
+}
+
+// substitute instantiates the replacement with the bound wildcards. All positions in the
+// result are set to pos so that the printer places it where the matched expression was.
+func (rw *rewriter) substitute(bindings map[string]reflect.Value, replacement reflect.Value, pos token.Pos) (reflect.Value, error) {
+	if !replacement.IsValid() {
+		return reflect.Value{}, nil
+	}
+	if replacement.Type() == identType {
+		ident := replacement.Interface().(*ast.Ident)
+		if bound, ok := bindings[ident.Name]; ok && isWildcard(ident.Name) {
+			if bound.Kind() == reflect.Interface {
+				bound = bound.Elem()
+			}
+			return bound, nil
+		}
+	}
+
+	switch replacement.Type() {
+	case positionType:
+		// keep unset positions unset, they carry meaning (e.g. CallExpr.Ellipsis)
+		if replacement.Interface().(token.Pos) == token.NoPos {
+			return replacement, nil
+		}
+		return reflect.ValueOf(pos), nil
+	case objectType, scopeType, commentGroupType:
+		return reflect.Zero(replacement.Type()), nil
+	}
+
+	switch replacement.Kind() {
+	case reflect.Slice:
+		if replacement.IsNil() {
+			return reflect.Zero(replacement.Type()), nil
+		}
+		result := reflect.MakeSlice(replacement.Type(), replacement.Len(), replacement.Len())
+		for i := 0; i < replacement.Len(); i++ {
+			if err := rw.substituteInto(result.Index(i), bindings, replacement.Index(i), pos); err != nil {
+				return reflect.Value{}, err
+			}
+		}
+		return result, nil
+	case reflect.Struct:
+		result := reflect.New(replacement.Type()).Elem()
+		for i := 0; i < replacement.NumField(); i++ {
+			if err := rw.substituteInto(result.Field(i), bindings, replacement.Field(i), pos); err != nil {
+				return reflect.Value{}, err
+			}
+		}
+		return result, nil
+	case reflect.Ptr:
+		if replacement.IsNil() {
+			return reflect.Zero(replacement.Type()), nil
+		}
+		result := reflect.New(replacement.Type().Elem())
+		if err := rw.substituteInto(result.Elem(), bindings, replacement.Elem(), pos); err != nil {
+			return reflect.Value{}, err
+		}
+		return result, nil
+	case reflect.Interface:
+		if replacement.IsNil() {
+			return reflect.Zero(replacement.Type()), nil
+		}
+		result := reflect.New(replacement.Type()).Elem()
+		if err := rw.substituteInto(result, bindings, replacement.Elem(), pos); err != nil {
+			return reflect.Value{}, err
+		}
+		return result, nil
+	}
+	return replacement, nil
+}
+
+// substituteInto instantiates the replacement and stores the result in dst. It is an error
+// if the result does not fit, e.g. when a wildcard bound to a call expression takes the
+// place of an identifier.
+func (rw *rewriter) substituteInto(dst reflect.Value, bindings map[string]reflect.Value, replacement reflect.Value, pos token.Pos) error {
+	value, err := rw.substitute(bindings, replacement, pos)
+	if err != nil || !value.IsValid() {
+		return err
+	}
+	if !value.Type().AssignableTo(dst.Type()) {
+		return errors.Errorf("Cannot use %s as %s in the replacement", value.Type(), dst.Type())
+	}
+	dst.Set(value)
+	return nil
+}
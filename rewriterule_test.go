@@ -0,0 +1,127 @@
+package gotransform
+
+import (
+	"go/build"
+	"go/constant"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRewriteRule(t *testing.T) {
+	tests := []struct {
+		name string
+		rule string
+		src  string
+		want string
+	}{
+		{
+			name: "swap arguments",
+			rule: "oldapi.Get(a, b) -> newapi.Lookup(b, a)",
+			src:  "package p\n\nvar x = oldapi.Get(1, f(2))\n",
+			want: "package p\n\nvar x = newapi.Lookup(f(2), 1)\n",
+		},
+		{
+			name: "repeated wildcard",
+			rule: "a + a -> 2 * a",
+			src:  "package p\n\nvar x, y = f() + f(), f() + g()\n",
+			want: "package p\n\nvar x, y = 2 * f(), f() + g()\n",
+		},
+		{
+			name: "nested matches",
+			rule: "double(a) -> a * 2",
+			src:  "package p\n\nvar x = double(double(1))\n",
+			want: "package p\n\nvar x = 1 * 2 * 2\n",
+		},
+		{
+			name: "wildcard as selector name",
+			rule: "get(o, f) -> o.f",
+			src:  "package p\n\nvar x = get(obj, field)\n",
+			want: "package p\n\nvar x = obj.field\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := transformSource(t, "p.go", test.src, RewriteRule(test.rule))
+			checkSource(t, got, test.want)
+		})
+	}
+}
+
+func TestRewriteRuleErrors(t *testing.T) {
+	tests := []struct {
+		name, rule, src, err string
+	}{
+		{"invalid rule", "a + ", "package p\n", "Invalid rule"},
+		{"missing arrow", "a + b", "package p\n", "Invalid rule"},
+		{"binding does not fit", "get(o, f) -> o.f", "package p\n\nvar x = get(obj, f())\n", "Cannot use *ast.CallExpr as *ast.Ident"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fset := token.NewFileSet()
+			file, err := parser.ParseFile(fset, "p.go", test.src, parser.ParseComments)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = RewriteRule(test.rule).Apply(FileContext{File: file, FileSet: fset})
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("Expected an error containing %q, got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestRewriteRuleWithTypes(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"p.go": "package p\n\nfunc f(a int, b string) {\n\t_ = len(a)\n\t_ = len(b)\n}\n",
+	})
+	rule := RewriteRuleWithTypes("len(s) -> utf8.RuneCountInString(s)", map[string]string{"s": "string"})
+	var got string
+	capture := &genericTransformation{apply: func(context FileContext) error {
+		got = formatNode(t, context)
+		return nil
+	}}
+	if err := ApplyWithOptions(dir, []FileTransformation{rule, capture}, Options{TypeCheck: true}); err != nil {
+		t.Fatal(err)
+	}
+	checkSource(t, got, "package p\n\nfunc f(a int, b string) {\n\t_ = len(a)\n\t_ = utf8.RuneCountInString(b)\n}\n")
+}
+
+func TestTypeCheckBuildContext(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"a_linux.go":   "package p\n\nfunc platform() string { return \"linux\" }\n",
+		"a_windows.go": "package p\n\nfunc platform() string { return \"windows\" }\n",
+		"b.go":         "//go:build special\n\npackage p\n\nfunc platform() string { return \"special\" }\n",
+		"c.go":         "package p\n\nimport \"unsafe\"\n\nconst size = unsafe.Sizeof(0)\n",
+	})
+	buildContext := build.Default
+	buildContext.GOOS, buildContext.GOARCH = "linux", "386"
+
+	typed := make(map[string]bool)
+	var intSize int64
+	capture := &genericTransformation{apply: func(context FileContext) error {
+		typed[filepath.Base(context.RelativePath)] = context.Info != nil
+		if context.Package != nil {
+			intSize, _ = constant.Int64Val(context.Package.Scope().Lookup("size").(*types.Const).Val())
+		}
+		return nil
+	}}
+	options := Options{TypeCheck: true, BuildContext: &buildContext}
+	if err := ApplyWithOptions(dir, []FileTransformation{capture}, options); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]bool{"a_linux.go": true, "a_windows.go": false, "b.go": false, "c.go": true}
+	for name, hasInfo := range want {
+		if typed[name] != hasInfo {
+			t.Errorf("Type information for %s: got %v, want %v", name, typed[name], hasInfo)
+		}
+	}
+	if intSize != 4 {
+		t.Errorf("Expected int to have 4 bytes on 386, got %d", intSize)
+	}
+}
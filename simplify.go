@@ -0,0 +1,110 @@
+package gotransform
+
+import (
+	"bytes"
+	"go/ast"
+	"go/printer"
+	"go/token"
+)
+
+// simplify applies the simplifications of gofmt -s to a file:
+//     []T{T{1}, T{2}}          becomes []T{{1}, {2}}
+//     []*T{&T{1}}              becomes []*T{{1}}
+//     s[a:len(s)]              becomes s[a:]
+//     for x, _ = range v {...} becomes for x = range v {...}
+//     for _ = range v {...}    becomes for range v {...}
+func simplify(fset *token.FileSet, f *ast.File) {
+	ast.Inspect(f, func(n ast.Node) bool {
+		switch node := n.(type) {
+		case *ast.CompositeLit:
+			simplifyCompositeLit(fset, node)
+		case *ast.SliceExpr:
+			if !node.Slice3 && isLenCall(node.High, node.X) {
+				node.High = nil
+			}
+		case *ast.RangeStmt:
+			if isBlank(node.Value) {
+				node.Value = nil
+			}
+			if isBlank(node.Key) && node.Value == nil {
+				node.Key = nil
+				node.Tok = token.ILLEGAL
+			}
+		}
+		return true
+	})
+}
+
+// simplifyCompositeLit removes the types of the elements of an array, slice or map literal
+// where they are implied by the type of the literal.
+func simplifyCompositeLit(fset *token.FileSet, lit *ast.CompositeLit) {
+	var keyType, elemType ast.Expr
+	switch typ := lit.Type.(type) {
+	case *ast.ArrayType:
+		elemType = typ.Elt
+	case *ast.MapType:
+		keyType, elemType = typ.Key, typ.Value
+	default:
+		return
+	}
+	for i, elt := range lit.Elts {
+		if kv, ok := elt.(*ast.KeyValueExpr); ok {
+			if keyType != nil {
+				kv.Key = simplifyElement(fset, kv.Key, keyType)
+			}
+			kv.Value = simplifyElement(fset, kv.Value, elemType)
+			continue
+		}
+		lit.Elts[i] = simplifyElement(fset, elt, elemType)
+	}
+}
+
+func simplifyElement(fset *token.FileSet, elt, typ ast.Expr) ast.Expr {
+	if inner, ok := elt.(*ast.CompositeLit); ok && sameExpr(fset, inner.Type, typ) {
+		inner.Type = nil
+		return inner
+	}
+	// &T{...} in a literal of element type *T
+	star, ok := typ.(*ast.StarExpr)
+	unary, isUnary := elt.(*ast.UnaryExpr)
+	if !ok || !isUnary || unary.Op != token.AND {
+		return elt
+	}
+	if inner, ok := unary.X.(*ast.CompositeLit); ok && sameExpr(fset, inner.Type, star.X) {
+		inner.Type = nil
+		return inner
+	}
+	return elt
+}
+
+// sameExpr compares two expressions by their printed form.
+func sameExpr(fset *token.FileSet, a, b ast.Expr) bool {
+	if a == nil || b == nil {
+		return false
+	}
+	var bufA, bufB bytes.Buffer
+	if printer.Fprint(&bufA, fset, a) != nil || printer.Fprint(&bufB, fset, b) != nil {
+		return false
+	}
+	return bufA.String() == bufB.String()
+}
+
+// isLenCall checks whether expr is the call len(x) for an identifier x.
+func isLenCall(expr, x ast.Expr) bool {
+	call, ok := expr.(*ast.CallExpr)
+	if !ok || len(call.Args) != 1 || call.Ellipsis.IsValid() {
+		return false
+	}
+	fun, ok := call.Fun.(*ast.Ident)
+	if !ok || fun.Name != "len" || fun.Obj != nil {
+		return false
+	}
+	ident, ok := x.(*ast.Ident)
+	arg, isIdent := call.Args[0].(*ast.Ident)
+	return ok && isIdent && ident.Name == arg.Name
+}
+
+func isBlank(expr ast.Expr) bool {
+	ident, ok := expr.(*ast.Ident)
+	return ok && ident.Name == "_"
+}
@@ -0,0 +1,233 @@
+package gotransform
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/build/constraint"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"io"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+)
+
+// FilePart is one of the files produced by SplitFile.
+type FilePart struct {
+	// FileName is the base name of the file, e.g. player.go
+	FileName string
+	Source   []byte
+}
+
+// SplitFile splits a file into one file per top-level type declaration. Each of them also
+// contains the methods of the type and its constructors, i.e. all functions returning
+// the type or a pointer to it. All remaining declarations are kept in a file named like the
+// original one. The files of the types are named by applying the naming convention to the
+// type name, e.g. SnakeCase turns the type PlayerState into player_state.go, or into
+// player_state_test.go if the original file is a test file. Each part only
+// imports the packages it uses and carries over the build constraints of the original file.
+// The names of the imported packages are taken from the type information if available (see
+// Options.TypeCheck) and otherwise read from the packages found from the file's directory.
+func SplitFile(context FileContext, naming NamingConvention) ([]FilePart, error) {
+	f := context.File
+	remainderName := filepath.Base(context.RelativePath)
+	extension := ".go"
+	if strings.HasSuffix(remainderName, "_test.go") {
+		extension = "_test.go"
+	}
+
+	// find the types and assign the declarations to them
+	typeFiles := make(map[string]string)
+	for _, decl := range f.Decls {
+		if genDecl, ok := decl.(*ast.GenDecl); ok && genDecl.Tok == token.TYPE {
+			for _, spec := range genDecl.Specs {
+				name := spec.(*ast.TypeSpec).Name.Name
+				typeFiles[name] = naming(name) + extension
+			}
+		}
+	}
+
+	parts := make(map[string][]ast.Decl)
+	order := []string{remainderName}
+	addDecl := func(fileName string, decl ast.Decl) {
+		if _, ok := parts[fileName]; !ok && fileName != remainderName {
+			order = append(order, fileName)
+		}
+		parts[fileName] = append(parts[fileName], decl)
+	}
+	for _, decl := range f.Decls {
+		switch d := decl.(type) {
+		case *ast.GenDecl:
+			if d.Tok == token.IMPORT {
+				continue
+			}
+			if d.Tok != token.TYPE {
+				addDecl(remainderName, d)
+				continue
+			}
+			if len(d.Specs) == 1 {
+				addDecl(typeFiles[d.Specs[0].(*ast.TypeSpec).Name.Name], d)
+				continue
+			}
+			// take the specs out of the group, moving their doc comments to the new declaration
+			for _, spec := range d.Specs {
+				typeSpec := *spec.(*ast.TypeSpec)
+				decl := &ast.GenDecl{Doc: typeSpec.Doc, TokPos: typeSpec.Pos(), Tok: token.TYPE, Specs: []ast.Spec{&typeSpec}}
+				typeSpec.Doc = nil
+				addDecl(typeFiles[typeSpec.Name.Name], decl)
+			}
+		case *ast.FuncDecl:
+			fileName := remainderName
+			if typeName := associatedType(d, typeFiles); len(typeName) > 0 {
+				fileName = typeFiles[typeName]
+			}
+			addDecl(fileName, d)
+		default:
+			addDecl(remainderName, decl)
+		}
+	}
+
+	var result []FilePart
+	for _, fileName := range order {
+		decls := parts[fileName]
+		if len(decls) == 0 && (fileName != remainderName || len(order) > 1) {
+			continue
+		}
+		source, err := printFilePart(context, decls, fileName == remainderName)
+		if err != nil {
+			return nil, errors.Wrapf(err, "SplitFile: Failed to print %s", fileName)
+		}
+		result = append(result, FilePart{fileName, source})
+	}
+	return result, nil
+}
+
+// associatedType returns the name of the type a function belongs to, which is the receiver
+// type for methods and the returned type for constructors.
+func associatedType(decl *ast.FuncDecl, types map[string]string) string {
+	if decl.Recv != nil {
+		name := receiverTypeName(decl)
+		if _, ok := types[name]; ok {
+			return name
+		}
+		return ""
+	}
+	if decl.Type.Results == nil {
+		return ""
+	}
+	for _, result := range decl.Type.Results.List {
+		typ := result.Type
+		if star, ok := typ.(*ast.StarExpr); ok {
+			typ = star.X
+		}
+		if ident, ok := typ.(*ast.Ident); ok {
+			if _, ok := types[ident.Name]; ok {
+				return ident.Name
+			}
+		}
+	}
+	return ""
+}
+
+func printFilePart(context FileContext, decls []ast.Decl, withPackageDoc bool) ([]byte, error) {
+	f := context.File
+	var buf bytes.Buffer
+	for _, c := range headerComments(f) {
+		if constraint.IsGoBuild(c.Text) || constraint.IsPlusBuild(c.Text) {
+			buf.WriteString(c.Text + "\n")
+		}
+	}
+	if buf.Len() > 0 {
+		buf.WriteString("\n")
+	}
+	if withPackageDoc && f.Doc != nil {
+		for _, c := range f.Doc.List {
+			buf.WriteString(c.Text + "\n")
+		}
+	}
+	fmt.Fprintf(&buf, "package %s\n", f.Name.Name)
+
+	// only import what is used in this part
+	used := make(map[string]bool)
+	for _, decl := range decls {
+		for name := range usedPackageNames(decl) {
+			used[name] = true
+		}
+	}
+	var imports []string
+	srcDir := sourceDir(context.FileSet, f)
+	for _, spec := range f.Imports {
+		name, _ := resolvedImportName(spec, context.Package, srcDir)
+		if used[name] || ((name == "_" || name == ".") && withPackageDoc) {
+			if spec.Name != nil {
+				imports = append(imports, spec.Name.Name+" "+spec.Path.Value)
+			} else {
+				imports = append(imports, spec.Path.Value)
+			}
+		}
+	}
+	if len(imports) == 1 {
+		fmt.Fprintf(&buf, "\nimport %s\n", imports[0])
+	} else if len(imports) > 1 {
+		fmt.Fprintf(&buf, "\nimport (\n\t%s\n)\n", strings.Join(imports, "\n\t"))
+	}
+
+	config := &printer.Config{Mode: printer.UseSpaces | printer.TabIndent, Tabwidth: 8}
+	for _, decl := range decls {
+		buf.WriteString("\n")
+		node := &printer.CommentedNode{Node: decl, Comments: f.Comments}
+		if err := config.Fprint(&buf, context.FileSet, node); err != nil {
+			return nil, err
+		}
+		buf.WriteString("\n")
+	}
+	return buf.Bytes(), nil
+}
+
+// WriteGoFileSplit parses the Go code from the reader, splits it with SplitFile and writes
+// the parts to the directory of the given path. The remaining declarations that are not
+// associated to any type end up in the file at path itself.
+func WriteGoFileSplit(path string, reader io.Reader, naming NamingConvention) error {
+	return WriteGoFileSplitWithOptions(path, reader, naming, DefaultGoWriteOptions())
+}
+
+// WriteGoFileSplitWithOptions is like WriteGoFileSplit, but uses the given options instead of
+// DefaultGoWriteOptions.
+func WriteGoFileSplitWithOptions(path string, reader io.Reader, naming NamingConvention, writeOptions GoWriteOptions) error {
+	fileset := token.NewFileSet()
+	file, err := parser.ParseFile(fileset, path, reader, parser.ParseComments)
+	if err != nil {
+		return errors.Wrapf(err, "WriteGoFileSplit: Failed to parse code for %s", path)
+	}
+	parts, err := SplitFile(FileContext{File: file, FileSet: fileset, RelativePath: filepath.Base(path)}, naming)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(path)
+	for _, part := range parts {
+		if err := WriteGoFileWithOptions(filepath.Join(dir, part.FileName), bytes.NewBuffer(part.Source), writeOptions); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteGoTemplateSplit applies the given template to the value and writes the result out
+// as several Go files, see WriteGoFileSplit.
+func WriteGoTemplateSplit(path string, tmpl *template.Template, value interface{}, naming NamingConvention) error {
+	return WriteGoTemplateSplitWithOptions(path, tmpl, value, naming, DefaultGoWriteOptions())
+}
+
+// WriteGoTemplateSplitWithOptions is like WriteGoTemplateSplit, but uses the given options
+// instead of DefaultGoWriteOptions.
+func WriteGoTemplateSplitWithOptions(path string, tmpl *template.Template, value interface{}, naming NamingConvention, writeOptions GoWriteOptions) error {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, value); err != nil {
+		return errors.Wrapf(err, "WriteGoTemplateSplit: Failed to write template to %s", path)
+	}
+	return WriteGoFileSplitWithOptions(path, &buf, naming, writeOptions)
+}
@@ -0,0 +1,116 @@
+package gotransform
+
+import (
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"testing"
+)
+
+func TestSplitFile(t *testing.T) {
+	// the module provides a package whose name differs from its path
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"go.mod":          "module example.com/m\n",
+		"helpers/util.go": "package util\n\ntype Vec struct{}\n",
+	})
+
+	tests := []struct {
+		name string
+		file string
+		src  string
+		want map[string]string
+	}{
+		{
+			name: "types with methods and constructors",
+			src: `//go:build linux
+
+// Package p is split.
+package p
+
+import "fmt"
+
+const Max = 3
+
+// Player is a player.
+type Player struct{}
+
+func NewPlayer() *Player { return nil }
+
+func (p *Player) String() string { return fmt.Sprint(Max) }
+
+type (
+	// Score counts.
+	Score int
+	Level int
+)
+`,
+			want: map[string]string{
+				"p.go":      "//go:build linux\n\n// Package p is split.\npackage p\n\nconst Max = 3\n",
+				"player.go": "//go:build linux\n\npackage p\n\nimport \"fmt\"\n\n// Player is a player.\ntype Player struct{}\n\nfunc NewPlayer() *Player { return nil }\n\nfunc (p *Player) String() string { return fmt.Sprint(Max) }\n",
+				"score.go":  "//go:build linux\n\npackage p\n\n// Score counts.\ntype Score int\n",
+				"level.go":  "//go:build linux\n\npackage p\n\ntype Level int\n",
+			},
+		},
+		{
+			name: "package names differing from paths",
+			src: `package p
+
+import (
+	"example.com/foo/v2"
+	"example.com/m/helpers"
+	"gopkg.in/yaml.v3"
+)
+
+var Config = yaml.Node{}
+
+type A struct{ v util.Vec }
+
+type B struct{ f foo.F }
+`,
+			want: map[string]string{
+				"p.go": "package p\n\nimport \"gopkg.in/yaml.v3\"\n\nvar Config = yaml.Node{}\n",
+				"a.go": "package p\n\nimport \"example.com/m/helpers\"\n\ntype A struct{ v util.Vec }\n",
+				"b.go": "package p\n\nimport \"example.com/foo/v2\"\n\ntype B struct{ f foo.F }\n",
+			},
+		},
+		{
+			name: "test files",
+			file: "p_test.go",
+			src:  "package p\n\nvar calls int\n\ntype FakeThing struct{}\n",
+			want: map[string]string{
+				"p_test.go":          "package p\n\nvar calls int\n",
+				"fake_thing_test.go": "package p\n\ntype FakeThing struct{}\n",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			name := test.file
+			if name == "" {
+				name = "p.go"
+			}
+			fset := token.NewFileSet()
+			path := filepath.Join(dir, name)
+			file, err := parser.ParseFile(fset, path, test.src, parser.ParseComments)
+			if err != nil {
+				t.Fatal(err)
+			}
+			parts, err := SplitFile(FileContext{File: file, FileSet: fset, RelativePath: name}, SnakeCase)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(parts) != len(test.want) {
+				t.Errorf("Expected %d parts, got %d", len(test.want), len(parts))
+			}
+			for _, part := range parts {
+				want, ok := test.want[part.FileName]
+				if !ok {
+					t.Errorf("Unexpected part %s", part.FileName)
+					continue
+				}
+				checkSource(t, formatSource(t, string(part.Source)), want)
+			}
+		})
+	}
+}
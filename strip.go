@@ -0,0 +1,331 @@
+package gotransform
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Declaration describes a top-level declaration of a file, see StripDeclarations.
+type Declaration struct {
+	// Kind is one of token.FUNC, token.TYPE, token.VAR and token.CONST.
+	Kind token.Token
+	// Names contains the declared names. Only variable and constant declarations
+	// such as `var a, b int` declare more than one name at once.
+	Names []string
+	// Receiver is the name of the receiver's base type for methods and empty otherwise.
+	Receiver string
+	// Doc is the doc comment of the declaration. For specs within a parenthesized
+	// declaration, this falls back to the doc comment of the whole group.
+	Doc *ast.CommentGroup
+	// Node is either an *ast.FuncDecl or an ast.Spec.
+	Node ast.Node
+}
+
+// StripDeclarations removes all top-level declarations from a file for which the predicate
+// returns true, together with their comments. When a type is removed, so are its methods,
+// also those declared in other files of the package.
+// Imports that were only used by the removed declarations are removed as well. Any other
+// remaining references to removed declarations, e.g. functions returning a removed type,
+// are reported as an error once all files have been processed, since the output would not
+// compile anymore.
+func StripDeclarations(predicate func(Declaration) bool) FileTransformation {
+	return &stripper{predicate: predicate}
+}
+
+// StripByDirective removes all top-level declarations annotated with a directive such as
+//     //gotransform:strip release editor
+// if any of the directive's arguments is among the given tags. A directive without
+// arguments always applies. For example, StripByDirective("release") removes the function
+//     //gotransform:strip release
+//     func DebugDump() { ... }
+// and leaves everything else untouched.
+func StripByDirective(tags ...string) FileTransformation {
+	return StripDeclarations(func(decl Declaration) bool {
+		args, found := findDirective(decl.Doc, "strip")
+		if !found {
+			return false
+		}
+		if len(args) == 0 {
+			return true
+		}
+		for _, arg := range args {
+			for _, tag := range tags {
+				if arg == tag {
+					return true
+				}
+			}
+		}
+		return false
+	})
+}
+
+// strippedPackageKey identifies a package by its directory and name.
+type strippedPackageKey struct{ dir, name string }
+
+// strippedPackage records what was removed from the files of a package and what the
+// remaining files still refer to.
+type strippedPackage struct {
+	// stripped contains the names of the removed top-level declarations, declared those
+	// of the remaining ones. A name can be in both if it is declared in several files, e.g.
+	// with different build constraints.
+	stripped, declared map[string]bool
+	// types contains the names of the removed types, whose methods are removed as well.
+	types      map[string]bool
+	references []strippedReference
+}
+
+// strippedReference is a reference to a top-level declaration of the package.
+type strippedReference struct {
+	name     string
+	position token.Position
+}
+
+type stripper struct {
+	predicate func(Declaration) bool
+	packages  map[strippedPackageKey]*strippedPackage
+}
+
+func (s *stripper) Prepare() error {
+	s.packages = make(map[strippedPackageKey]*strippedPackage)
+	return nil
+}
+
+// Validate collects the stripped types of all packages, so that their methods are removed
+// from files that are visited before the one declaring the type.
+func (s *stripper) Validate(collection []FileContext) error {
+	for _, context := range collection {
+		s.strippedTypes(context)
+	}
+	return nil
+}
+
+func (s *stripper) Apply(context FileContext) error {
+	s.strip(context)
+	return nil
+}
+
+func (s *stripper) Finalize() error {
+	return s.danglingReferences()
+}
+
+// strippedPackage returns the record of the package of a file.
+func (s *stripper) strippedPackage(context FileContext) *strippedPackage {
+	if s.packages == nil {
+		s.packages = make(map[strippedPackageKey]*strippedPackage)
+	}
+	key := strippedPackageKey{filepath.Dir(context.RelativePath), context.File.Name.Name}
+	pkg, ok := s.packages[key]
+	if !ok {
+		pkg = &strippedPackage{
+			stripped: make(map[string]bool),
+			declared: make(map[string]bool),
+			types:    make(map[string]bool),
+		}
+		s.packages[key] = pkg
+	}
+	return pkg
+}
+
+// strippedTypes records the types of a file that are removed in its package and returns
+// their specs.
+func (s *stripper) strippedTypes(context FileContext) map[interface{}]bool {
+	pkg := s.strippedPackage(context)
+	removed := make(map[interface{}]bool)
+	for _, decl := range context.File.Decls {
+		if d, ok := decl.(*ast.GenDecl); ok && d.Tok == token.TYPE {
+			for _, spec := range d.Specs {
+				if s.predicate(describeSpec(d, spec)) {
+					removed[spec] = true
+					pkg.types[spec.(*ast.TypeSpec).Name.Name] = true
+				}
+			}
+		}
+	}
+	return removed
+}
+
+func (s *stripper) strip(context FileContext) {
+	pkg := s.strippedPackage(context)
+	f := context.File
+	usedBefore := usedPackageNames(f)
+	topLevel := topLevelNodes(f)
+	// remove types first, so that their methods can be removed regardless of their order
+	removed := s.strippedTypes(context)
+
+	decls := f.Decls[:0]
+	for _, decl := range f.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			description := describeFunc(d)
+			if pkg.types[description.Receiver] || s.predicate(description) {
+				if description.Receiver == "" {
+					pkg.stripped[d.Name.Name] = true
+				}
+				removeComments(f, d, d.Doc)
+				continue
+			}
+			if description.Receiver == "" {
+				pkg.declared[d.Name.Name] = true
+			}
+		case *ast.GenDecl:
+			if d.Tok == token.IMPORT {
+				break
+			}
+			specs := d.Specs[:0]
+			for _, spec := range d.Specs {
+				description := describeSpec(d, spec)
+				if removed[spec] || (d.Tok != token.TYPE && s.predicate(description)) {
+					for _, name := range description.Names {
+						pkg.stripped[name] = true
+					}
+					removeComments(f, spec, specComments(spec)...)
+					continue
+				}
+				for _, name := range description.Names {
+					pkg.declared[name] = true
+				}
+				specs = append(specs, spec)
+			}
+			d.Specs = specs
+			if len(specs) == 0 {
+				removeComments(f, d, d.Doc)
+				continue
+			}
+		}
+		decls = append(decls, decl)
+	}
+	f.Decls = decls
+	deleteDeadImports(context.FileSet, f, usedBefore)
+	pkg.references = append(pkg.references, packageReferences(context.FileSet, f, topLevel)...)
+}
+
+// topLevelNodes collects the top-level declarations of a file, i.e. the nodes that objects
+// declared at the top level point to.
+func topLevelNodes(f *ast.File) map[interface{}]bool {
+	nodes := make(map[interface{}]bool)
+	for _, decl := range f.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			nodes[d] = true
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				nodes[spec] = true
+			}
+		}
+	}
+	return nodes
+}
+
+// packageReferences collects all identifiers of a file that may refer to top-level
+// declarations of its package: those resolved to the given top-level declarations of the
+// file and those that the parser could not resolve, since they may be declared in other files.
+func packageReferences(fset *token.FileSet, f *ast.File, topLevel map[interface{}]bool) []strippedReference {
+	unresolved := make(map[*ast.Ident]bool, len(f.Unresolved))
+	for _, ident := range f.Unresolved {
+		unresolved[ident] = true
+	}
+	var references []strippedReference
+	for _, decl := range f.Decls {
+		ast.Inspect(decl, func(n ast.Node) bool {
+			ident, ok := n.(*ast.Ident)
+			if !ok {
+				return true
+			}
+			// the declaring identifiers are included as well, but they are never dangling
+			if unresolved[ident] || (ident.Obj != nil && topLevel[ident.Obj.Decl]) {
+				references = append(references, strippedReference{ident.Name, fset.Position(ident.Pos())})
+			}
+			return true
+		})
+	}
+	return references
+}
+
+// danglingReferences reports all references to declarations that were removed and are
+// not declared anywhere else in the package.
+func (s *stripper) danglingReferences() error {
+	var problems []string
+	for _, pkg := range s.packages {
+		for _, reference := range pkg.references {
+			if pkg.stripped[reference.name] && !pkg.declared[reference.name] {
+				problems = append(problems, fmt.Sprintf("%s: %s refers to a stripped declaration", reference.position, reference.name))
+			}
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	sort.Strings(problems)
+	return errors.Errorf("StripDeclarations: The remaining code refers to stripped declarations:\n%s", strings.Join(problems, "\n"))
+}
+
+func describeFunc(decl *ast.FuncDecl) Declaration {
+	return Declaration{
+		Kind:     token.FUNC,
+		Names:    []string{decl.Name.Name},
+		Receiver: receiverTypeName(decl),
+		Doc:      decl.Doc,
+		Node:     decl,
+	}
+}
+
+func describeSpec(decl *ast.GenDecl, spec ast.Spec) Declaration {
+	result := Declaration{Kind: decl.Tok, Doc: decl.Doc, Node: spec}
+	switch s := spec.(type) {
+	case *ast.TypeSpec:
+		result.Names = []string{s.Name.Name}
+		if s.Doc != nil {
+			result.Doc = s.Doc
+		}
+	case *ast.ValueSpec:
+		for _, name := range s.Names {
+			result.Names = append(result.Names, name.Name)
+		}
+		if s.Doc != nil {
+			result.Doc = s.Doc
+		}
+	}
+	return result
+}
+
+// specComments returns the doc and line comments of a spec.
+func specComments(spec ast.Spec) []*ast.CommentGroup {
+	switch s := spec.(type) {
+	case *ast.TypeSpec:
+		return []*ast.CommentGroup{s.Doc, s.Comment}
+	case *ast.ValueSpec:
+		return []*ast.CommentGroup{s.Doc, s.Comment}
+	}
+	return nil
+}
+
+// receiverTypeName returns the name of the base type of a method's receiver, or the empty
+// string for functions.
+func receiverTypeName(decl *ast.FuncDecl) string {
+	if decl.Recv == nil || len(decl.Recv.List) == 0 {
+		return ""
+	}
+	typ := decl.Recv.List[0].Type
+	for {
+		switch t := typ.(type) {
+		case *ast.StarExpr:
+			typ = t.X
+		case *ast.ParenExpr:
+			typ = t.X
+		case *ast.IndexExpr:
+			typ = t.X
+		case *ast.IndexListExpr:
+			typ = t.X
+		case *ast.Ident:
+			return t.Name
+		default:
+			return ""
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package gotransform
+
+import (
+	"go/token"
+	"strings"
+	"testing"
+)
+
+func TestStripByDirective(t *testing.T) {
+	tests := []struct {
+		name string
+		tags []string
+		src  string
+		want string
+	}{
+		{
+			name: "function and unused import",
+			tags: []string{"release"},
+			src: `package p
+
+import (
+	"fmt"
+	"os"
+)
+
+//gotransform:strip release
+func Dump() { fmt.Println(os.Args) }
+
+func Keep() { os.Exit(0) }
+`,
+			want: `package p
+
+import (
+	"os"
+)
+
+func Keep() { os.Exit(0) }
+`,
+		},
+		{
+			name: "other tags are kept",
+			tags: []string{"release"},
+			src:  "package p\n\n//gotransform:strip editor\nfunc Edit() {}\n",
+			want: "package p\n\n//gotransform:strip editor\nfunc Edit() {}\n",
+		},
+		{
+			name: "directive without arguments",
+			tags: []string{"release"},
+			src:  "package p\n\n//gotransform:strip\nvar debug = true\n\nvar x = 1\n",
+			want: "package p\n\nvar x = 1\n",
+		},
+		{
+			name: "spec in a group",
+			tags: []string{"release"},
+			src:  "package p\n\nconst (\n\tA = 1\n\t//gotransform:strip release\n\tB = 2\n)\n",
+			want: "package p\n\nconst (\n\tA = 1\n)\n",
+		},
+		{
+			name: "methods of stripped types",
+			tags: []string{"release"},
+			src: `package p
+
+func (d *Debugger) Break() {}
+
+//gotransform:strip release
+type Debugger struct{}
+
+func (d Debugger) Name() string { return "" }
+
+type List[T any] struct{}
+
+func (l *List[T]) Len() int { return 0 }
+`,
+			want: `package p
+
+type List[T any] struct{}
+
+func (l *List[T]) Len() int { return 0 }
+`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := transformSource(t, "p.go", test.src, StripByDirective(test.tags...))
+			checkSource(t, got, test.want)
+		})
+	}
+}
+
+func TestStripDeclarations(t *testing.T) {
+	src := "package p\n\nfunc TestOnly() {}\n\nfunc (x X) TestMethod() {}\n\ntype X int\n\nvar TestVar, Other = 1, 2\n"
+	want := "package p\n\ntype X int\n\nvar TestVar, Other = 1, 2\n"
+	// specs declaring several names are only removed as a whole
+	got := transformSource(t, "p.go", src, StripDeclarations(func(decl Declaration) bool {
+		return decl.Kind == token.FUNC && strings.HasPrefix(decl.Names[0], "Test")
+	}))
+	checkSource(t, got, want)
+}
+
+func TestStripDanglingReferences(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  map[string]string
+		err   string
+	}{
+		{
+			name: "function returning stripped type",
+			files: map[string]string{
+				"a.go": "package p\n\n//gotransform:strip release\ntype Debugger struct{}\n\nfunc NewDebugger() *Debugger { return nil }\n",
+			},
+			err: "a.go:6:21: Debugger refers to a stripped declaration",
+		},
+		{
+			name: "method in another file",
+			files: map[string]string{
+				"a.go": "package p\n\nfunc (d *Debugger) Break() {}\n",
+				"b.go": "package p\n\n//gotransform:strip release\ntype Debugger struct{}\n",
+			},
+			want: map[string]string{
+				"a.go": "package p\n",
+				"b.go": "package p\n",
+			},
+		},
+		{
+			name: "declared again in another file",
+			files: map[string]string{
+				"a.go":       "package p\n\nvar _ = debug()\n",
+				"debug.go":   "package p\n\n//gotransform:strip release\nfunc debug() bool { return true }\n",
+				"release.go": "package p\n\nfunc debug() bool { return false }\n",
+			},
+		},
+		{
+			name: "same name in another package",
+			files: map[string]string{
+				"a/a.go": "package a\n\n//gotransform:strip release\nfunc debug() {}\n",
+				"b/b.go": "package b\n\nfunc f() { debug() }\n",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := transformPackage(t, test.files, StripByDirective("release"))
+			if test.err == "" {
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				for name, want := range test.want {
+					checkSource(t, got[name], want)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("Expected an error containing %q, got %v", test.err, err)
+			}
+		})
+	}
+}
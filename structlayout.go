@@ -0,0 +1,344 @@
+package gotransform
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io"
+	"sort"
+
+	"github.com/pkg/errors"
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// OptimizeStructLayout reorders the fields of top-level structs such that the padding between
+// them is minimized. Sizes and alignments are computed for the given GOARCH (e.g. "amd64")
+// with the gc compiler's rules. Since the order of the fields is visible to code using them,
+// only structs annotated with
+//     //gotransform:optimizelayout
+// are reordered, and it is an error if the package uses such a struct in an unkeyed
+// composite literal, in a conversion, or in a call to a function of package unsafe. Structs
+// containing blank fields (which usually serve as explicit padding) are left alone. For every
+// reordered struct, a line stating the bytes saved is written to the report when the
+// transformation is finalized; report may be nil.
+// This transformation requires type information, see Options.TypeCheck. Since it has to
+// reparse the files it modifies, the type information of these files is no longer valid
+// for the transformations following it.
+func OptimizeStructLayout(goarch string, report io.Writer) FileTransformation {
+	sizes := types.SizesFor("gc", goarch)
+	if sizes == nil {
+		return failingTransformation(errors.Errorf("OptimizeStructLayout: Unknown architecture %s", goarch))
+	}
+	var entries []layoutReportEntry
+	return &genericTransformation{
+		prepare: func() error {
+			entries = nil
+			return nil
+		},
+		apply: func(context FileContext) error {
+			if context.Info == nil {
+				return errors.New("OptimizeStructLayout: Type information is required, enable Options.TypeCheck")
+			}
+			fileEntries, err := optimizeStructLayout(context, sizes)
+			if err != nil {
+				return errors.Wrapf(err, "OptimizeStructLayout: Failed to optimize %s", context.RelativePath)
+			}
+			entries = append(entries, fileEntries...)
+			return nil
+		},
+		finalize: func() error {
+			if report == nil {
+				return nil
+			}
+			for _, entry := range entries {
+				_, err := fmt.Fprintf(report, "%s:%d: %s: %d -> %d bytes (saved %d)\n",
+					entry.path, entry.line, entry.name, entry.oldSize, entry.newSize, entry.oldSize-entry.newSize)
+				if err != nil {
+					return errors.Wrap(err, "OptimizeStructLayout: Failed to write report")
+				}
+			}
+			return nil
+		},
+	}
+}
+
+type layoutReportEntry struct {
+	path             string
+	line             int
+	name             string
+	oldSize, newSize int64
+}
+
+// structLocation identifies a struct by the position of its declaration in the file.
+type structLocation struct {
+	decl, spec int
+}
+
+func optimizeStructLayout(context FileContext, sizes types.Sizes) ([]layoutReportEntry, error) {
+	entries := make(map[structLocation]layoutReportEntry)
+	orders := make(map[structLocation][]int)
+	for d, decl := range context.File.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.TYPE {
+			continue
+		}
+		for s, spec := range genDecl.Specs {
+			typeSpec := spec.(*ast.TypeSpec)
+			struc, ok := typeSpec.Type.(*ast.StructType)
+			if !ok || !hasDirective("optimizelayout", typeSpec.Doc, genDecl.Doc) {
+				continue
+			}
+			if err := checkLayoutIndependence(context, typeSpec); err != nil {
+				return nil, err
+			}
+			order, oldSize, newSize, ok := optimalFieldOrder(struc, context.Info, sizes)
+			if !ok || newSize >= oldSize {
+				continue
+			}
+			orders[structLocation{d, s}] = order
+			entries[structLocation{d, s}] = layoutReportEntry{
+				path:    context.RelativePath,
+				line:    context.FileSet.Position(typeSpec.Pos()).Line,
+				name:    typeSpec.Name.Name,
+				oldSize: oldSize,
+				newSize: newSize,
+			}
+		}
+	}
+	if len(orders) == 0 {
+		return nil, nil
+	}
+	reordered, err := reorderFields(context, orders)
+	if err != nil {
+		return nil, err
+	}
+	var result []layoutReportEntry
+	for _, location := range reordered {
+		result = append(result, entries[location])
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].line < result[j].line })
+	return result, nil
+}
+
+// hasDirective checks whether a directive is present in any of the comment groups.
+func hasDirective(name string, docs ...*ast.CommentGroup) bool {
+	for _, doc := range docs {
+		if _, found := findDirective(doc, name); found {
+			return true
+		}
+	}
+	return false
+}
+
+// checkLayoutIndependence makes sure that the package does not depend on the order of the
+// fields of the given struct type, i.e. that it neither uses the type in unkeyed composite
+// literals, nor converts values from or to it, nor passes it to package unsafe.
+func checkLayoutIndependence(context FileContext, typeSpec *ast.TypeSpec) error {
+	obj := context.Info.Defs[typeSpec.Name]
+	if obj == nil {
+		return errors.Errorf("No type information for %s", typeSpec.Name.Name)
+	}
+	target := obj.Type()
+	matches := func(typ types.Type) bool {
+		if ptr, ok := typ.(*types.Pointer); ok {
+			typ = ptr.Elem()
+		}
+		return typ != nil && types.Identical(typ, target)
+	}
+	type use struct {
+		pos  token.Pos
+		kind string
+	}
+	var uses []use
+	for expr, tv := range context.Info.Types {
+		switch e := expr.(type) {
+		case *ast.CompositeLit:
+			if len(e.Elts) > 0 && matches(tv.Type) {
+				if _, keyed := e.Elts[0].(*ast.KeyValueExpr); !keyed {
+					uses = append(uses, use{e.Pos(), "an unkeyed composite literal"})
+				}
+			}
+		case *ast.CallExpr:
+			if fun, ok := context.Info.Types[e.Fun]; ok && fun.IsType() && len(e.Args) == 1 {
+				if matches(tv.Type) || matches(context.Info.TypeOf(e.Args[0])) {
+					uses = append(uses, use{e.Pos(), "a conversion"})
+				}
+				continue
+			}
+			if !isUnsafeCall(e, context.Info) {
+				continue
+			}
+			for _, arg := range e.Args {
+				ast.Inspect(arg, func(n ast.Node) bool {
+					if argExpr, ok := n.(ast.Expr); ok && matches(context.Info.TypeOf(argExpr)) {
+						uses = append(uses, use{e.Pos(), "a call to package unsafe"})
+						return false
+					}
+					return true
+				})
+			}
+		}
+	}
+	if len(uses) == 0 {
+		return nil
+	}
+	sort.Slice(uses, func(i, j int) bool { return uses[i].pos < uses[j].pos })
+	return errors.Errorf("Cannot reorder the fields of %s, as it is used in %s at %s",
+		typeSpec.Name.Name, uses[0].kind, context.FileSet.Position(uses[0].pos))
+}
+
+// isUnsafeCall checks whether call calls one of the functions of package unsafe.
+func isUnsafeCall(call *ast.CallExpr, info *types.Info) bool {
+	sel, ok := astutil.Unparen(call.Fun).(*ast.SelectorExpr)
+	if !ok {
+		return false
+	}
+	ident, ok := sel.X.(*ast.Ident)
+	if !ok {
+		return false
+	}
+	pkgName, ok := info.Uses[ident].(*types.PkgName)
+	return ok && pkgName.Imported().Path() == "unsafe"
+}
+
+type layoutField struct {
+	index       int
+	size, align int64
+}
+
+// optimalFieldOrder computes an order of the fields of a struct with minimal padding:
+// zero-sized fields come first, as they would need padding at the end of a struct, followed
+// by the remaining fields with decreasing alignment and size.
+func optimalFieldOrder(struc *ast.StructType, info *types.Info, sizes types.Sizes) (order []int, oldSize, newSize int64, ok bool) {
+	if struc.Fields == nil || len(struc.Fields.List) < 2 {
+		return nil, 0, 0, false
+	}
+	fields := make([]layoutField, len(struc.Fields.List))
+	vars := make([][]*types.Var, len(struc.Fields.List))
+	for i, field := range struc.Fields.List {
+		typ := info.TypeOf(field.Type)
+		if typ == nil {
+			return nil, 0, 0, false
+		}
+		if len(field.Names) == 0 {
+			vars[i] = []*types.Var{types.NewField(field.Pos(), nil, EmbeddedFieldName(field.Type), typ, true)}
+		}
+		for _, name := range field.Names {
+			if name.Name == "_" {
+				return nil, 0, 0, false
+			}
+			vars[i] = append(vars[i], types.NewField(name.Pos(), nil, name.Name, typ, false))
+		}
+		fields[i] = layoutField{i, sizes.Sizeof(typ) * int64(len(vars[i])), sizes.Alignof(typ)}
+	}
+
+	sorted := append([]layoutField(nil), fields...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if (a.size == 0) != (b.size == 0) {
+			return a.size == 0
+		}
+		if a.align != b.align {
+			return a.align > b.align
+		}
+		return a.size > b.size
+	})
+	order = make([]int, len(sorted))
+	for i, field := range sorted {
+		order[i] = field.index
+	}
+	return order, structSize(vars, nil, sizes), structSize(vars, order, sizes), true
+}
+
+// structSize computes the size of a struct with the given fields in the given order.
+func structSize(vars [][]*types.Var, order []int, sizes types.Sizes) int64 {
+	var fields []*types.Var
+	for i := range vars {
+		idx := i
+		if order != nil {
+			idx = order[i]
+		}
+		fields = append(fields, vars[idx]...)
+	}
+	return sizes.Sizeof(types.NewStruct(fields, nil))
+}
+
+// reorderFields applies the new field orders to the structs of a file. To keep the comments
+// of the fields in place, this is done on the printed source of the file, which is parsed
+// again afterwards. Structs containing comments that do not belong to any field are skipped,
+// as there is no sensible place to move them to. The locations of the reordered structs
+// are returned.
+func reorderFields(context FileContext, orders map[structLocation][]int) ([]structLocation, error) {
+	var buf bytes.Buffer
+	if err := format.Node(&buf, context.FileSet, context.File); err != nil {
+		return nil, errors.Wrap(err, "Failed to print file")
+	}
+	src := buf.Bytes()
+	filename := context.FileSet.Position(context.File.Package).Filename
+	fileset := token.NewFileSet()
+	printed, err := parser.ParseFile(fileset, filename, src, parser.ParseComments)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to parse printed file")
+	}
+	tokenFile := fileset.File(printed.Package)
+
+	type edit struct {
+		start, end  int
+		replacement []byte
+	}
+	var edits []edit
+	var reordered []structLocation
+	for location, order := range orders {
+		typeSpec := printed.Decls[location.decl].(*ast.GenDecl).Specs[location.spec].(*ast.TypeSpec)
+		struc := typeSpec.Type.(*ast.StructType)
+		fieldTexts := make([][]byte, len(struc.Fields.List))
+		attached := make(map[*ast.CommentGroup]bool)
+		for i, field := range struc.Fields.List {
+			start, end := field.Pos(), field.End()
+			if field.Doc != nil {
+				start = field.Doc.Pos()
+				attached[field.Doc] = true
+			}
+			if field.Comment != nil {
+				end = field.Comment.End()
+				attached[field.Comment] = true
+			}
+			fieldTexts[i] = src[tokenFile.Offset(start):tokenFile.Offset(end)]
+		}
+		detached := false
+		for _, group := range printed.Comments {
+			if group.Pos() > struc.Fields.Opening && group.End() < struc.Fields.Closing && !attached[group] {
+				detached = true
+			}
+		}
+		if detached {
+			continue
+		}
+		var body bytes.Buffer
+		body.WriteString("\n")
+		for _, idx := range order {
+			body.Write(fieldTexts[idx])
+			body.WriteString("\n")
+		}
+		reordered = append(reordered, location)
+		edits = append(edits, edit{
+			start:       tokenFile.Offset(struc.Fields.Opening) + 1,
+			end:         tokenFile.Offset(struc.Fields.Closing),
+			replacement: body.Bytes(),
+		})
+	}
+
+	// apply the edits back to front, so that the offsets stay valid
+	sort.Slice(edits, func(i, j int) bool { return edits[i].start > edits[j].start })
+	for _, e := range edits {
+		src = append(src[:e.start:e.start], append(e.replacement, src[e.end:]...)...)
+	}
+	if len(edits) == 0 {
+		return nil, nil
+	}
+	return reordered, replaceFile(context.FileSet, context.File, filename, src)
+}
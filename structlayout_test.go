@@ -0,0 +1,155 @@
+package gotransform
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestOptimizeStructLayout(t *testing.T) {
+	tests := []struct {
+		name   string
+		src    string
+		want   string
+		report string
+	}{
+		{
+			name: "annotated struct",
+			src: `package p
+
+//gotransform:optimizelayout
+type Particle struct {
+	// Alive is set for active particles.
+	Alive bool
+	X     float64 // in meters
+	Kind  int32
+	Empty struct{}
+}
+
+type Other struct {
+	A bool
+	B float64
+	C bool
+}
+`,
+			want: `package p
+
+//gotransform:optimizelayout
+type Particle struct {
+	Empty struct{}
+	X     float64 // in meters
+	Kind  int32
+	// Alive is set for active particles.
+	Alive bool
+}
+
+type Other struct {
+	A bool
+	B float64
+	C bool
+}
+`,
+			report: "p.go:4: Particle: 24 -> 16 bytes (saved 8)\n",
+		},
+		{
+			name: "keyed literals",
+			src: `package p
+
+type (
+	//gotransform:optimizelayout
+	T struct {
+		A bool
+		B int64
+		C bool
+	}
+)
+
+var _ = T{A: true, C: true}
+
+var _ = []T{{}}
+`,
+			want: `package p
+
+type (
+	//gotransform:optimizelayout
+	T struct {
+		B int64
+		A bool
+		C bool
+	}
+)
+
+var _ = T{A: true, C: true}
+
+var _ = []T{{}}
+`,
+			report: "p.go:5: T: 24 -> 16 bytes (saved 8)\n",
+		},
+		{
+			name: "already optimal",
+			src:  "package p\n\n//gotransform:optimizelayout\ntype T struct {\n\tB int64\n\tA bool\n}\n",
+			want: "package p\n\n//gotransform:optimizelayout\ntype T struct {\n\tB int64\n\tA bool\n}\n",
+		},
+		{
+			name: "blank fields",
+			src:  "package p\n\n//gotransform:optimizelayout\ntype T struct {\n\tA bool\n\t_ [7]byte\n\tB int64\n\tC bool\n}\n",
+			want: "package p\n\n//gotransform:optimizelayout\ntype T struct {\n\tA bool\n\t_ [7]byte\n\tB int64\n\tC bool\n}\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var report bytes.Buffer
+			got, err := transformPackage(t, map[string]string{"p.go": test.src}, OptimizeStructLayout("amd64", &report))
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkSource(t, got["p.go"], test.want)
+			if report.String() != test.report {
+				t.Errorf("Expected report %q, got %q", test.report, report.String())
+			}
+		})
+	}
+}
+
+func TestOptimizeStructLayoutErrors(t *testing.T) {
+	const decl = "package p\n\nimport \"unsafe\"\n\nvar _ unsafe.Pointer\n\n//gotransform:optimizelayout\ntype T struct {\n\tA bool\n\tB int64\n\tC bool\n}\n\n"
+	tests := []struct {
+		name  string
+		files map[string]string
+		err   string
+	}{
+		{
+			name:  "unkeyed literal",
+			files: map[string]string{"p.go": decl + "var _ = &T{true, 1, false}\n"},
+			err:   "used in an unkeyed composite literal at",
+		},
+		{
+			name:  "unkeyed literal in another file",
+			files: map[string]string{"p.go": decl, "q.go": "package p\n\nvar _ = []T{{true, 1, false}}\n"},
+			err:   "used in an unkeyed composite literal at",
+		},
+		{
+			name:  "conversion",
+			files: map[string]string{"p.go": decl + "type U struct {\n\tA bool\n\tB int64\n\tC bool\n}\n\nvar _ = U(T{})\n"},
+			err:   "used in a conversion at",
+		},
+		{
+			name:  "pointer conversion",
+			files: map[string]string{"p.go": decl + "var _ = (*T)(unsafe.Pointer(nil))\n"},
+			err:   "used in a conversion at",
+		},
+		{
+			name:  "offset",
+			files: map[string]string{"p.go": decl + "var _ = unsafe.Offsetof(T{}.C)\n"},
+			err:   "used in a call to package unsafe at",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := transformPackage(t, test.files, OptimizeStructLayout("amd64", nil))
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("Expected an error containing %q, got %v", test.err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,206 @@
+package tagparser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Value is a tag value split into a name and a list of flags, as used by encoding/json:
+// the value "name,omitempty" consists of the name "name" and the flag "omitempty".
+type Value struct {
+	Name  string
+	Flags []string
+}
+
+// SplitValue splits a tag value at each occurrence of the separator into a name and flags,
+// e.g. SplitValue("name,omitempty", ",").
+func SplitValue(value, separator string) Value {
+	parts := strings.Split(value, separator)
+	return Value{Name: parts[0], Flags: parts[1:]}
+}
+
+// HasFlag checks whether the value carries the given flag.
+func (v Value) HasFlag(flag string) bool {
+	for _, f := range v.Flags {
+		if f == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// OptionSyntax describes how options are written in a tag value.
+type OptionSyntax struct {
+	// Separator separates the options, e.g. "," or ";".
+	Separator string
+	// Assign separates the key of an option from its value, e.g. "=" or ":".
+	Assign string
+}
+
+// DefaultOptionSyntax reads options of the form "key=value,other=1".
+var DefaultOptionSyntax = OptionSyntax{Separator: ",", Assign: "="}
+
+// Option is a single option. Options without a value, e.g. "enabled" in "enabled,size=2",
+// are flags.
+type Option struct {
+	Key, Value string
+	HasValue   bool
+}
+
+// Options is a list of key-value options parsed from a tag value.
+type Options []Option
+
+// OptionError is returned by the accessors of Options when the value of an option cannot
+// be converted to the requested type.
+type OptionError struct {
+	Key, Value string
+	// Type is the name of the requested type, e.g. "int"
+	Type string
+	Err  error
+}
+
+func (oe *OptionError) Error() string {
+	return fmt.Sprintf("Option %s: Invalid %s %q: %v", oe.Key, oe.Type, oe.Value, oe.Err)
+}
+
+func (oe *OptionError) Unwrap() error { return oe.Err }
+
+// ParseOptions parses a tag value consisting of options, such as "key=value;other=1" with
+// the separator ";" and the assignment "=". Whitespace around keys and values is ignored,
+// as are empty options. It is an error if a key is empty or occurs more than once.
+func ParseOptions(value string, syntax OptionSyntax) (Options, error) {
+	if len(syntax.Separator) == 0 || len(syntax.Assign) == 0 {
+		return nil, fmt.Errorf("Invalid option syntax %+v", syntax)
+	}
+	var result Options
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(value, syntax.Separator) {
+		part = strings.TrimSpace(part)
+		if len(part) == 0 {
+			continue
+		}
+		option := Option{Key: part}
+		if idx := strings.Index(part, syntax.Assign); idx >= 0 {
+			option = Option{
+				Key:      strings.TrimSpace(part[:idx]),
+				Value:    strings.TrimSpace(part[idx+len(syntax.Assign):]),
+				HasValue: true,
+			}
+		}
+		if len(option.Key) == 0 {
+			return result, fmt.Errorf("Option without key in %q", value)
+		}
+		if seen[option.Key] {
+			return result, fmt.Errorf("Option %s is given more than once in %q", option.Key, value)
+		}
+		seen[option.Key] = true
+		result = append(result, option)
+	}
+	return result, nil
+}
+
+// Lookup returns the option with the given key.
+func (o Options) Lookup(key string) (Option, bool) {
+	for _, option := range o {
+		if option.Key == key {
+			return option, true
+		}
+	}
+	return Option{}, false
+}
+
+// Has checks whether an option with the given key is present.
+func (o Options) Has(key string) bool {
+	_, ok := o.Lookup(key)
+	return ok
+}
+
+// Keys returns the keys of all options in order.
+func (o Options) Keys() []string {
+	keys := make([]string, len(o))
+	for i, option := range o {
+		keys[i] = option.Key
+	}
+	return keys
+}
+
+// StringOr returns the value of an option, or the default if it is not present.
+func (o Options) StringOr(key, def string) string {
+	if option, ok := o.Lookup(key); ok {
+		return option.Value
+	}
+	return def
+}
+
+// Bool returns the value of an option as a bool, or the default if it is not present.
+// A flag, i.e. an option without value, is true.
+func (o Options) Bool(key string, def bool) (bool, error) {
+	option, ok := o.Lookup(key)
+	if !ok {
+		return def, nil
+	}
+	if !option.HasValue {
+		return true, nil
+	}
+	value, err := strconv.ParseBool(option.Value)
+	if err != nil {
+		return def, &OptionError{key, option.Value, "bool", err}
+	}
+	return value, nil
+}
+
+// Int returns the value of an option as an int, or the default if it is not present.
+func (o Options) Int(key string, def int) (int, error) {
+	option, ok := o.Lookup(key)
+	if !ok {
+		return def, nil
+	}
+	value, err := strconv.Atoi(option.Value)
+	if err != nil {
+		return def, &OptionError{key, option.Value, "int", err}
+	}
+	return value, nil
+}
+
+// Float returns the value of an option as a float64, or the default if it is not present.
+func (o Options) Float(key string, def float64) (float64, error) {
+	option, ok := o.Lookup(key)
+	if !ok {
+		return def, nil
+	}
+	value, err := strconv.ParseFloat(option.Value, 64)
+	if err != nil {
+		return def, &OptionError{key, option.Value, "float", err}
+	}
+	return value, nil
+}
+
+// Duration returns the value of an option as a time.Duration written like "1.5s", or the
+// default if it is not present.
+func (o Options) Duration(key string, def time.Duration) (time.Duration, error) {
+	option, ok := o.Lookup(key)
+	if !ok {
+		return def, nil
+	}
+	value, err := time.ParseDuration(option.Value)
+	if err != nil {
+		return def, &OptionError{key, option.Value, "duration", err}
+	}
+	return value, nil
+}
+
+// List returns the value of an option split at the separator, e.g. the option "names=a|b"
+// yields [a b] with the separator "|". If the option is not present, the result is nil.
+func (o Options) List(key, separator string) []string {
+	option, ok := o.Lookup(key)
+	if !ok || len(option.Value) == 0 {
+		return nil
+	}
+	parts := strings.Split(option.Value, separator)
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+	return parts
+}
@@ -0,0 +1,117 @@
+package tagparser
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSplitValue(t *testing.T) {
+	value := SplitValue("name,omitempty,string", ",")
+	if value.Name != "name" || !reflect.DeepEqual(value.Flags, []string{"omitempty", "string"}) {
+		t.Errorf("Unexpected value %+v", value)
+	}
+	if !value.HasFlag("omitempty") || value.HasFlag("name") {
+		t.Errorf("Unexpected flags of %+v", value)
+	}
+	if value := SplitValue("", ","); value.Name != "" || len(value.Flags) != 0 {
+		t.Errorf("Unexpected value %+v", value)
+	}
+}
+
+func TestParseOptions(t *testing.T) {
+	tests := []struct {
+		name   string
+		value  string
+		syntax OptionSyntax
+		want   Options
+		err    string
+	}{
+		{name: "empty", value: "", syntax: DefaultOptionSyntax},
+		{
+			name:   "default syntax",
+			value:  "size=3, enabled,, name = a b ",
+			syntax: DefaultOptionSyntax,
+			want:   Options{{"size", "3", true}, {"enabled", "", false}, {"name", "a b", true}},
+		},
+		{
+			name:   "custom syntax",
+			value:  "path:a=b;empty:",
+			syntax: OptionSyntax{Separator: ";", Assign: ":"},
+			want:   Options{{"path", "a=b", true}, {"empty", "", true}},
+		},
+		{name: "missing key", value: "a=1,=2", syntax: DefaultOptionSyntax, err: `Option without key in "a=1,=2"`},
+		{name: "duplicate key", value: "a=1,a", syntax: DefaultOptionSyntax, err: "Option a is given more than once"},
+		{name: "invalid syntax", value: "a", syntax: OptionSyntax{Separator: ","}, err: "Invalid option syntax"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ParseOptions(test.value, test.syntax)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Errorf("Expected an error containing %q, got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("Expected %+v, got %+v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestOptionAccessors(t *testing.T) {
+	options, err := ParseOptions("name=x,flag,off=false,n=16,f=1.5,d=1m30s,list=a | b|c,bad=?", DefaultOptionSyntax)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := options.StringOr("name", "def"); got != "x" {
+		t.Errorf("Expected x, got %q", got)
+	}
+	if got := options.StringOr("missing", "def"); got != "def" {
+		t.Errorf("Expected the default, got %q", got)
+	}
+	if !options.Has("flag") || options.Has("missing") {
+		t.Errorf("Unexpected result of Has")
+	}
+	if got := options.Keys(); !reflect.DeepEqual(got, []string{"name", "flag", "off", "n", "f", "d", "list", "bad"}) {
+		t.Errorf("Unexpected keys %v", got)
+	}
+	if got, err := options.Bool("flag", false); err != nil || !got {
+		t.Errorf("Expected a flag to be true, got %v, %v", got, err)
+	}
+	if got, err := options.Bool("off", true); err != nil || got {
+		t.Errorf("Expected false, got %v, %v", got, err)
+	}
+	if got, err := options.Int("n", 0); err != nil || got != 16 {
+		t.Errorf("Expected 16, got %v, %v", got, err)
+	}
+	if got, err := options.Int("missing", 7); err != nil || got != 7 {
+		t.Errorf("Expected the default, got %v, %v", got, err)
+	}
+	if got, err := options.Float("f", 0); err != nil || got != 1.5 {
+		t.Errorf("Expected 1.5, got %v, %v", got, err)
+	}
+	if got, err := options.Duration("d", 0); err != nil || got != 90*time.Second {
+		t.Errorf("Expected 1m30s, got %v, %v", got, err)
+	}
+	if got := options.List("list", "|"); !reflect.DeepEqual(got, []string{"a", "b", "c"}) {
+		t.Errorf("Unexpected list %q", got)
+	}
+	if got := options.List("missing", "|"); got != nil {
+		t.Errorf("Expected nil, got %q", got)
+	}
+
+	_, err = options.Int("bad", 0)
+	optionErr, ok := err.(*OptionError)
+	if !ok || optionErr.Key != "bad" || optionErr.Type != "int" {
+		t.Fatalf("Expected an *OptionError for bad, got %v", err)
+	}
+	if want := `Option bad: Invalid int "?"`; !strings.HasPrefix(err.Error(), want) {
+		t.Errorf("Expected an error starting with %q, got %v", want, err)
+	}
+}
@@ -2,45 +2,109 @@ package tagparser
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 )
 
 // Parse takes a struct field tag in Go's standard format,
 // that is key:"value" pairs separated by spaces, and turns it into a map
-// from keys to values, removing all "" around values in the process.
+// from keys to values, removing all "" around values and resolving escape
+// sequences in the process.
 // For example, a field tag such as
 //     `protobuf:"1"`
 // would be turned into the map
 //      { "protobuf": [ "1" ] }
 func Parse(fieldTag string) (result map[string][]string, err error) {
-	fieldTag = strings.TrimSpace(fieldTag)
 	result = make(map[string][]string)
-	for len(fieldTag) > 0 {
-		delimPos := strings.IndexRune(fieldTag, ':')
-		if delimPos < 0 {
-			return result, fmt.Errorf("Invalid format in struct tag: %s", fieldTag)
-		}
-		key := fieldTag[:delimPos]
-		fieldTag = strings.TrimSpace(fieldTag[delimPos+1:])
-		valueStartPos := strings.IndexRune(fieldTag, '"')
-		if valueStartPos != 0 {
-			return result, fmt.Errorf("Invalid format in struct tag: %s", fieldTag)
-		}
-		fieldTag = fieldTag[1:]
-		valueEndPos := strings.IndexRune(fieldTag, '"')
-		if valueEndPos < 0 {
-			return result, fmt.Errorf("Invalid format in struct tag: %s", fieldTag)
-		}
-		value := fieldTag[:valueEndPos]
-		existingValues, ok := result[key]
-		if !ok {
-			result[key] = []string{value}
-		} else {
-			result[key] = append(existingValues, value)
-		}
-		fieldTag = strings.TrimSpace(fieldTag[valueEndPos+1:])
+	tags, err := ParseList(fieldTag)
+	for _, tag := range tags {
+		result[tag.Key] = append(result[tag.Key], tag.Value)
 	}
-	return result, nil
+	return result, err
+}
+
+// Tag is a single key:"value" pair of a struct field tag.
+type Tag struct {
+	Key   string
+	Value string
+}
+
+// SyntaxError describes a malformed struct field tag.
+type SyntaxError struct {
+	// Offset is the byte offset in the field tag at which the error was detected.
+	Offset int
+	Msg    string
+}
+
+func (se *SyntaxError) Error() string {
+	return fmt.Sprintf("Invalid struct tag at byte %d: %s", se.Offset, se.Msg)
+}
+
+// ParseList is like Parse, but returns the key-value pairs in the order in which they
+// appear in the field tag, including duplicate keys. Use this when the tag is to be
+// modified and written out again with Format.
+// The syntax is exactly the one accepted by reflect.StructTag: pairs are separated by
+// spaces, keys consist of non-control characters other than space, quote and colon, and
+// values are double-quoted Go string literals directly following the colon. Where
+// reflect.StructTag silently ignores the rest of a malformed tag, ParseList returns the
+// pairs parsed so far together with a *SyntaxError.
+func ParseList(fieldTag string) (result []Tag, err error) {
+	i := 0
+	for {
+		// skip leading space
+		for i < len(fieldTag) && fieldTag[i] == ' ' {
+			i++
+		}
+		if i == len(fieldTag) {
+			return result, nil
+		}
+
+		// scan to colon; a space, a quote or a control character is a syntax error
+		keyStart := i
+		for i < len(fieldTag) && fieldTag[i] > ' ' && fieldTag[i] != ':' && fieldTag[i] != '"' && fieldTag[i] != 0x7f {
+			i++
+		}
+		if i == keyStart {
+			return result, &SyntaxError{i, fmt.Sprintf("Expected key, found %q", fieldTag[i])}
+		}
+		if i == len(fieldTag) || fieldTag[i] != ':' {
+			return result, &SyntaxError{i, fmt.Sprintf("Expected ':' after key %s", fieldTag[keyStart:i])}
+		}
+		key := fieldTag[keyStart:i]
+		i++
+		if i == len(fieldTag) || fieldTag[i] != '"' {
+			return result, &SyntaxError{i, fmt.Sprintf("Expected '\"' after %s:", key)}
+		}
+
+		// scan quoted string to find value
+		valueStart := i
+		i++
+		for i < len(fieldTag) && fieldTag[i] != '"' {
+			if fieldTag[i] == '\\' {
+				i++
+			}
+			i++
+		}
+		if i >= len(fieldTag) {
+			return result, &SyntaxError{valueStart, fmt.Sprintf("Unterminated value of key %s", key)}
+		}
+		i++
+		value, err := strconv.Unquote(fieldTag[valueStart:i])
+		if err != nil {
+			return result, &SyntaxError{valueStart, fmt.Sprintf("Invalid value of key %s: %v", key, err)}
+		}
+		result = append(result, Tag{key, value})
+	}
+}
+
+// Format turns a list of key-value pairs back into a struct field tag, that is the
+// inverse of ParseList. Values are quoted and escaped as needed.
+func Format(tags []Tag) string {
+	parts := make([]string, len(tags))
+	for i, tag := range tags {
+		parts[i] = tag.Key + ":" + strconv.Quote(tag.Value)
+	}
+	return strings.Join(parts, " ")
 }
 
 // Unique maps each value in a list-valued map to its first element.
@@ -0,0 +1,86 @@
+package tagparser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseList(t *testing.T) {
+	tests := []struct {
+		name string
+		tag  string
+		want []Tag
+		err  string
+	}{
+		{name: "empty", tag: ""},
+		{name: "spaces only", tag: "   "},
+		{name: "single", tag: `json:"name"`, want: []Tag{{"json", "name"}}},
+		{
+			name: "order and duplicates",
+			tag:  `yaml:"b"  json:"a,omitempty" yaml:"c"`,
+			want: []Tag{{"yaml", "b"}, {"json", "a,omitempty"}, {"yaml", "c"}},
+		},
+		{name: "escapes", tag: `doc:"a \"b\"\n\\" x:""`, want: []Tag{{"doc", "a \"b\"\n\\"}, {"x", ""}}},
+		{name: "unusual keys", tag: `a.b-c/d:"1" ü:"2"`, want: []Tag{{"a.b-c/d", "1"}, {"ü", "2"}}},
+		{name: "missing colon", tag: `json:"a" yaml`, want: []Tag{{"json", "a"}}, err: `Invalid struct tag at byte 13: Expected ':' after key yaml`},
+		{name: "space before value", tag: `json: "a"`, err: `Invalid struct tag at byte 5: Expected '"' after json:`},
+		{name: "quote in key", tag: `"json":"a"`, err: `Invalid struct tag at byte 0: Expected key, found '"'`},
+		{name: "unterminated", tag: `json:"a\"`, err: `Invalid struct tag at byte 5: Unterminated value of key json`},
+		{name: "invalid escape", tag: `json:"\q"`, err: `Invalid struct tag at byte 5: Invalid value of key json: invalid syntax`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ParseList(test.tag)
+			if test.err != "" {
+				if err == nil || err.Error() != test.err {
+					t.Errorf("Expected error %q, got %v", test.err, err)
+				}
+				if _, ok := err.(*SyntaxError); !ok {
+					t.Errorf("Expected a *SyntaxError, got %T", err)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("Expected %q, got %q", test.want, got)
+			}
+			if test.err != "" {
+				return
+			}
+			// valid tags are understood exactly like reflect.StructTag does
+			for _, tag := range got {
+				if value, _ := reflect.StructTag(test.tag).Lookup(tag.Key); value != firstValue(got, tag.Key) {
+					t.Errorf("reflect.StructTag has %q for %s, got %q", value, tag.Key, firstValue(got, tag.Key))
+				}
+			}
+			// and formatting them again yields an equivalent tag
+			again, err := ParseList(Format(got))
+			if err != nil || !reflect.DeepEqual(again, got) {
+				t.Errorf("Round trip through %s failed: %q, %v", Format(got), again, err)
+			}
+		})
+	}
+}
+
+func firstValue(tags []Tag, key string) string {
+	for _, tag := range tags {
+		if tag.Key == key {
+			return tag.Value
+		}
+	}
+	return ""
+}
+
+func TestParse(t *testing.T) {
+	got, err := Parse(`a:"1" b:"2" a:"3"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]string{"a": {"1", "3"}, "b": {"2"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+	if unique := Unique(got); !reflect.DeepEqual(unique, map[string]string{"a": "1", "b": "2"}) {
+		t.Errorf("Unexpected unique values %v", unique)
+	}
+}
@@ -0,0 +1,138 @@
+package tagparser
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Tags is a struct field tag that can be modified and written out again. Keys keep the
+// order in which they appear in the original tag; new keys are appended at the end.
+type Tags struct {
+	list []Tag
+}
+
+// ParseTags parses a struct field tag, see ParseList.
+func ParseTags(fieldTag string) (*Tags, error) {
+	list, err := ParseList(fieldTag)
+	if err != nil {
+		return nil, err
+	}
+	return &Tags{list: list}, nil
+}
+
+// NewTags creates a struct field tag from the given key-value pairs.
+func NewTags(tags ...Tag) *Tags {
+	return &Tags{list: append([]Tag(nil), tags...)}
+}
+
+// Len returns the number of key-value pairs.
+func (t *Tags) Len() int {
+	return len(t.list)
+}
+
+// List returns a copy of the key-value pairs in order.
+func (t *Tags) List() []Tag {
+	return append([]Tag(nil), t.list...)
+}
+
+// Keys returns the keys in order. Keys that occur more than once are only listed once.
+func (t *Tags) Keys() []string {
+	var keys []string
+	seen := make(map[string]bool)
+	for _, tag := range t.list {
+		if !seen[tag.Key] {
+			seen[tag.Key] = true
+			keys = append(keys, tag.Key)
+		}
+	}
+	return keys
+}
+
+// Get returns the value of the first pair with the given key.
+func (t *Tags) Get(key string) (string, bool) {
+	for _, tag := range t.list {
+		if tag.Key == key {
+			return tag.Value, true
+		}
+	}
+	return "", false
+}
+
+// GetAll returns the values of all pairs with the given key.
+func (t *Tags) GetAll(key string) []string {
+	var values []string
+	for _, tag := range t.list {
+		if tag.Key == key {
+			values = append(values, tag.Value)
+		}
+	}
+	return values
+}
+
+// Set sets the value of a key. If the key is present, its first occurrence is changed in
+// place and all further occurrences are removed; otherwise, the key is appended.
+func (t *Tags) Set(key, value string) {
+	for i, tag := range t.list {
+		if tag.Key == key {
+			t.list[i].Value = value
+			t.list = append(t.list[:i+1], removeKey(t.list[i+1:], key)...)
+			return
+		}
+	}
+	t.list = append(t.list, Tag{key, value})
+}
+
+// Add appends a pair, even if the key is already present.
+func (t *Tags) Add(key, value string) {
+	t.list = append(t.list, Tag{key, value})
+}
+
+// Delete removes all pairs with the given key and reports whether there were any.
+func (t *Tags) Delete(key string) bool {
+	n := len(t.list)
+	t.list = removeKey(t.list, key)
+	return len(t.list) != n
+}
+
+func removeKey(list []Tag, key string) []Tag {
+	result := list[:0]
+	for _, tag := range list {
+		if tag.Key != key {
+			result = append(result, tag)
+		}
+	}
+	return result
+}
+
+// Options returns the value of a key split into a name and flags at commas, like
+// encoding/json uses them; see SplitValue.
+func (t *Tags) Options(key string) (Value, bool) {
+	value, ok := t.Get(key)
+	if !ok {
+		return Value{}, false
+	}
+	return SplitValue(value, ","), true
+}
+
+// SetOptions sets the value of a key to a name and flags joined by commas.
+func (t *Tags) SetOptions(key string, value Value) {
+	t.Set(key, strings.Join(append([]string{value.Name}, value.Flags...), ","))
+}
+
+// String formats the tag in canonical struct tag syntax, see Format.
+func (t *Tags) String() string {
+	return Format(t.list)
+}
+
+// Literal returns the tag as a Go string literal as it appears in a struct declaration,
+// i.e. in backquotes if possible. It is empty if there are no pairs.
+func (t *Tags) Literal() string {
+	if len(t.list) == 0 {
+		return ""
+	}
+	formatted := t.String()
+	if strings.ContainsRune(formatted, '`') {
+		return strconv.Quote(formatted)
+	}
+	return "`" + formatted + "`"
+}
@@ -0,0 +1,121 @@
+package tagparser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTags(t *testing.T) {
+	tests := []struct {
+		name    string
+		tag     string
+		edit    func(tags *Tags)
+		want    string
+		literal string
+	}{
+		{
+			name:    "untouched",
+			tag:     `b:"1"   a:"2"`,
+			edit:    func(tags *Tags) {},
+			want:    `b:"1" a:"2"`,
+			literal: "`b:\"1\" a:\"2\"`",
+		},
+		{
+			name:    "set keeps the position",
+			tag:     `b:"1" a:"2" c:"3"`,
+			edit:    func(tags *Tags) { tags.Set("a", "x") },
+			want:    `b:"1" a:"x" c:"3"`,
+			literal: "`b:\"1\" a:\"x\" c:\"3\"`",
+		},
+		{
+			name:    "set removes duplicates",
+			tag:     `a:"1" b:"2" a:"3"`,
+			edit:    func(tags *Tags) { tags.Set("a", "x") },
+			want:    `a:"x" b:"2"`,
+			literal: "`a:\"x\" b:\"2\"`",
+		},
+		{
+			name: "new keys are appended",
+			tag:  `b:"1"`,
+			edit: func(tags *Tags) {
+				tags.Set("a", "2")
+				tags.Add("b", "3")
+			},
+			want:    `b:"1" a:"2" b:"3"`,
+			literal: "`b:\"1\" a:\"2\" b:\"3\"`",
+		},
+		{
+			name:    "delete",
+			tag:     `a:"1" b:"2" a:"3"`,
+			edit:    func(tags *Tags) { tags.Delete("a") },
+			want:    `b:"2"`,
+			literal: "`b:\"2\"`",
+		},
+		{
+			name:    "delete everything",
+			tag:     `a:"1"`,
+			edit:    func(tags *Tags) { tags.Delete("a") },
+			want:    ``,
+			literal: "",
+		},
+		{
+			name: "options",
+			tag:  `json:"name" yaml:"x"`,
+			edit: func(tags *Tags) {
+				value, _ := tags.Options("json")
+				tags.SetOptions("json", Value{Name: value.Name, Flags: append(value.Flags, "omitempty")})
+			},
+			want:    `json:"name,omitempty" yaml:"x"`,
+			literal: "`json:\"name,omitempty\" yaml:\"x\"`",
+		},
+		{
+			name:    "escapes and backquotes",
+			tag:     `doc:"a\tb"`,
+			edit:    func(tags *Tags) { tags.Set("code", "`x`") },
+			want:    "doc:\"a\\tb\" code:\"`x`\"",
+			literal: `"doc:\"a\\tb\" code:\"` + "`x`" + `\""`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tags, err := ParseTags(test.tag)
+			if err != nil {
+				t.Fatal(err)
+			}
+			test.edit(tags)
+			if got := tags.String(); got != test.want {
+				t.Errorf("Expected %s, got %s", test.want, got)
+			}
+			if got := tags.Literal(); got != test.literal {
+				t.Errorf("Expected the literal %s, got %s", test.literal, got)
+			}
+		})
+	}
+}
+
+func TestTagsAccessors(t *testing.T) {
+	tags := NewTags(Tag{"a", "1"}, Tag{"b", "2"}, Tag{"a", "3"})
+	if value, ok := tags.Get("a"); !ok || value != "1" {
+		t.Errorf("Expected the first value of a, got %q", value)
+	}
+	if _, ok := tags.Get("c"); ok {
+		t.Errorf("Expected c to be missing")
+	}
+	if got := tags.GetAll("a"); !reflect.DeepEqual(got, []string{"1", "3"}) {
+		t.Errorf("Unexpected values %q", got)
+	}
+	if got := tags.Keys(); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Errorf("Unexpected keys %q", got)
+	}
+	list := tags.List()
+	list[0].Value = "changed"
+	if value, _ := tags.Get("a"); value != "1" || tags.Len() != 3 {
+		t.Errorf("List must return a copy")
+	}
+	if tags.Delete("c") || !tags.Delete("b") {
+		t.Errorf("Unexpected result of Delete")
+	}
+	if _, err := ParseTags(`a:1`); err == nil {
+		t.Errorf("Expected a syntax error")
+	}
+}
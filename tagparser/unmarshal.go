@@ -0,0 +1,269 @@
+package tagparser
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// schemaTagKey is the key of the field tags that describe how Unmarshal fills a struct.
+const schemaTagKey = "tag"
+
+var (
+	durationType        = reflect.TypeOf(time.Duration(0))
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// Unmarshal parses a struct field tag (see ParseList) and stores its values in the struct
+// pointed to by v. This allows tag handlers to declare the keys they understand as a struct:
+//     type generatorOptions struct {
+//         Name    string        `tag:"name,required"`
+//         Mode    string        `tag:"mode,default=fast,enum=fast|small"`
+//         Timeout time.Duration `tag:"timeout,default=1s"`
+//         Labels  []string      `tag:"labels,sep=|"`
+//     }
+//     var opts generatorOptions
+//     err := tagparser.Unmarshal(literalTag, &opts)
+// The field tag with the key "tag" gives the key of the struct tag that is stored in a field,
+// followed by these options:
+//     required     the key has to be present
+//     default=X    the value used if the key is not present
+//     enum=A|B|C   the allowed values, or the allowed elements of a slice
+//     sep=S        the separator of the elements of a slice, "," by default
+//     exclusive=G  at most one of the keys with the same group G may be given
+// Fields without such a field tag use their name with a lowercase first letter as the key,
+// and fields tagged with `tag:"-"` as well as unexported fields are ignored. Supported field
+// types are strings, bools, integers, floats, time.Duration, slices of these, and types
+// implementing encoding.TextUnmarshaler. Keys in the tag that do not belong to any field
+// and keys that occur more than once are errors.
+func Unmarshal(fieldTag string, v interface{}) error {
+	target := reflect.ValueOf(v)
+	if target.Kind() != reflect.Ptr || target.IsNil() || target.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("Unmarshal: Expected a pointer to a struct, got %T", v)
+	}
+	fields, err := schemaFields(target.Elem().Type())
+	if err != nil {
+		return err
+	}
+	tags, err := ParseList(fieldTag)
+	if err != nil {
+		return err
+	}
+
+	values := make(map[string]string, len(tags))
+	for _, tag := range tags {
+		if _, ok := values[tag.Key]; ok {
+			return fmt.Errorf("Unmarshal: Key %s is given more than once", tag.Key)
+		}
+		if _, ok := fields[tag.Key]; !ok {
+			return fmt.Errorf("Unmarshal: Unknown key %s, expected one of %s", tag.Key, strings.Join(sortedKeys(fields), ", "))
+		}
+		values[tag.Key] = tag.Value
+	}
+
+	groups := make(map[string][]string)
+	for _, key := range sortedKeys(fields) {
+		if group := fields[key].exclusive; len(group) > 0 {
+			if _, ok := values[key]; ok {
+				groups[group] = append(groups[group], key)
+			}
+		}
+	}
+	for _, keys := range groups {
+		if len(keys) > 1 {
+			return fmt.Errorf("Unmarshal: Keys %s are mutually exclusive", strings.Join(keys, ", "))
+		}
+	}
+
+	for _, key := range sortedKeys(fields) {
+		field := fields[key]
+		value, ok := values[key]
+		if !ok {
+			if field.required {
+				return fmt.Errorf("Unmarshal: Missing required key %s", key)
+			}
+			if !field.hasDefault {
+				continue
+			}
+			value = field.def
+		}
+		fieldValue := target.Elem().FieldByIndex(field.index)
+		if len(field.enum) > 0 {
+			for _, element := range enumElements(fieldValue, value, field.separator) {
+				if !contains(field.enum, element) {
+					return fmt.Errorf("Unmarshal: Invalid value %q for key %s, expected one of %s", element, key, strings.Join(field.enum, ", "))
+				}
+			}
+		}
+		if err := setValue(fieldValue, value, field.separator); err != nil {
+			return fmt.Errorf("Unmarshal: Invalid value %q for key %s: %v", value, key, err)
+		}
+	}
+	return nil
+}
+
+type schemaField struct {
+	index      []int
+	required   bool
+	hasDefault bool
+	def        string
+	enum       []string
+	separator  string
+	exclusive  string
+}
+
+// schemaFields reads the description of the keys from the fields of a struct type.
+func schemaFields(typ reflect.Type) (map[string]schemaField, error) {
+	fields := make(map[string]schemaField)
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		spec, hasSpec := f.Tag.Lookup(schemaTagKey)
+		if spec == "-" {
+			continue
+		}
+		key := lowerFirst(f.Name)
+		field := schemaField{index: f.Index, separator: ","}
+		if hasSpec {
+			parts := strings.SplitN(spec, ",", 2)
+			if len(parts[0]) > 0 {
+				key = parts[0]
+			}
+			if len(parts) > 1 {
+				// the options are separated by commas as well, so enumerations and separators
+				// use their own syntax
+				options, err := ParseOptions(parts[1], DefaultOptionSyntax)
+				if err != nil {
+					return nil, fmt.Errorf("Unmarshal: Invalid schema of field %s: %v", f.Name, err)
+				}
+				for _, option := range options {
+					switch option.Key {
+					case "required":
+						field.required = true
+					case "default":
+						field.hasDefault, field.def = true, option.Value
+					case "enum":
+						field.enum = options.List("enum", "|")
+					case "sep":
+						field.separator = option.Value
+					case "exclusive":
+						field.exclusive = option.Value
+					default:
+						return nil, fmt.Errorf("Unmarshal: Unknown option %s in schema of field %s", option.Key, f.Name)
+					}
+				}
+			}
+		}
+		if _, ok := fields[key]; ok {
+			return nil, fmt.Errorf("Unmarshal: Key %s is used by more than one field", key)
+		}
+		fields[key] = field
+	}
+	return fields, nil
+}
+
+// enumElements returns the parts of a value that have to be one of the values of an
+// enumeration: the elements for slices, and the value itself for all other fields.
+func enumElements(field reflect.Value, value, separator string) []string {
+	if field.Kind() != reflect.Slice || (field.CanAddr() && field.Addr().Type().Implements(textUnmarshalerType)) {
+		return []string{value}
+	}
+	if len(value) == 0 {
+		return nil
+	}
+	parts := strings.Split(value, separator)
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+	return parts
+}
+
+// setValue converts a string and stores it in a struct field.
+func setValue(field reflect.Value, value, separator string) error {
+	if field.CanAddr() && field.Addr().Type().Implements(textUnmarshalerType) {
+		return field.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(value))
+	}
+	if field.Type() == durationType {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 0, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 0, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+	case reflect.Slice:
+		if len(value) == 0 {
+			field.Set(reflect.MakeSlice(field.Type(), 0, 0))
+			return nil
+		}
+		parts := strings.Split(value, separator)
+		slice := reflect.MakeSlice(field.Type(), len(parts), len(parts))
+		for i, part := range parts {
+			if err := setValue(slice.Index(i), strings.TrimSpace(part), separator); err != nil {
+				return err
+			}
+		}
+		field.Set(slice)
+	default:
+		return fmt.Errorf("Unsupported field type %s", field.Type())
+	}
+	return nil
+}
+
+func lowerFirst(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	return string(unicode.ToLower(r)) + s[size:]
+}
+
+func contains(list []string, s string) bool {
+	for _, entry := range list {
+		if entry == s {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(fields map[string]schemaField) []string {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,114 @@
+package tagparser
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type upperText string
+
+func (u *upperText) UnmarshalText(text []byte) error {
+	*u = upperText(strings.ToUpper(string(text)))
+	return nil
+}
+
+type testOptions struct {
+	Name    string        `tag:"name,required"`
+	Mode    string        `tag:"mode,default=fast,enum=fast|small"`
+	Timeout time.Duration `tag:"timeout,default=1s"`
+	Labels  []string      `tag:"labels,sep=|,enum=a|b|c"`
+	Sizes   []int
+	Enabled bool `tag:"enabled,exclusive=state"`
+	Off     bool `tag:"off,exclusive=state"`
+	Ratio   float32
+	Count   uint8
+	Text    upperText
+	Skipped string `tag:"-"`
+	hidden  string
+}
+
+func TestUnmarshal(t *testing.T) {
+	tests := []struct {
+		name string
+		tag  string
+		want testOptions
+		err  string
+	}{
+		{
+			name: "defaults",
+			tag:  `name:"x"`,
+			want: testOptions{Name: "x", Mode: "fast", Timeout: time.Second},
+		},
+		{
+			name: "all keys",
+			tag:  `name:"x" mode:"small" timeout:"2m" labels:"a| c" sizes:"1,0x10" enabled:"true" ratio:"0.5" count:"255" text:"abc"`,
+			want: testOptions{
+				Name: "x", Mode: "small", Timeout: 2 * time.Minute, Labels: []string{"a", "c"}, Sizes: []int{1, 16},
+				Enabled: true, Ratio: 0.5, Count: 255, Text: "ABC",
+			},
+		},
+		{
+			name: "empty slice",
+			tag:  `name:"x" labels:""`,
+			want: testOptions{Name: "x", Mode: "fast", Timeout: time.Second, Labels: []string{}},
+		},
+		{name: "exclusive keys", tag: `name:"x" off:"true" enabled:"false"`, err: "Keys enabled, off are mutually exclusive"},
+		{name: "missing required key", tag: `mode:"fast"`, err: "Missing required key name"},
+		{name: "unknown key", tag: `name:"x" skipped:"y"`, err: "Unknown key skipped, expected one of count, enabled, labels, mode, name, off, ratio, sizes, text, timeout"},
+		{name: "duplicate key", tag: `name:"x" name:"y"`, err: "Key name is given more than once"},
+		{name: "value outside of enum", tag: `name:"x" mode:"slow"`, err: `Invalid value "slow" for key mode, expected one of fast, small`},
+		{name: "element outside of enum", tag: `name:"x" labels:"a|d"`, err: `Invalid value "d" for key labels, expected one of a, b, c`},
+		{name: "all elements in enum", tag: `name:"x" labels:"a|b"`, want: testOptions{Name: "x", Mode: "fast", Timeout: time.Second, Labels: []string{"a", "b"}}},
+		{name: "invalid number", tag: `name:"x" count:"256"`, err: `Invalid value "256" for key count`},
+		{name: "invalid element", tag: `name:"x" sizes:"1,x"`, err: `Invalid value "1,x" for key sizes`},
+		{name: "syntax error", tag: `name:x`, err: "Invalid struct tag at byte 5"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got testOptions
+			err := Unmarshal(test.tag, &got)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Errorf("Expected an error containing %q, got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("Expected %+v, got %+v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestUnmarshalInvalidSchema(t *testing.T) {
+	tests := []struct {
+		name string
+		v    interface{}
+		err  string
+	}{
+		{name: "no pointer", v: testOptions{}, err: "Expected a pointer to a struct"},
+		{name: "unknown option", v: &struct {
+			A string `tag:"a,optional"`
+		}{}, err: "Unknown option optional in schema of field A"},
+		{name: "duplicate key", v: &struct {
+			A string `tag:"x"`
+			B string `tag:"x"`
+		}{}, err: "Key x is used by more than one field"},
+		{name: "unsupported type", v: &struct {
+			A map[string]string
+		}{}, err: "Unsupported field type map[string]string"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := Unmarshal(`a:"1"`, test.v)
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("Expected an error containing %q, got %v", test.err, err)
+			}
+		})
+	}
+}
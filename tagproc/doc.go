@@ -7,6 +7,7 @@
 //     }
 // The TagProcessor allows you to register handlers for the tags.ImportantStruct type
 // that are called for all declarations that have an embedded (i.e. anonymous) member
-// of this kind. A type may be used as a tag whenever it is defined in a package named
-// tags.
+// of this kind. By default, a type may be used as a tag whenever it is defined in a package
+// whose import path ends in tags; set TagProcessor.TagPackages to choose the tag packages
+// explicitly.
 package tagproc
@@ -10,7 +10,8 @@ import (
 
 // NewCollectionTemplater creates a TagHandler that will collect the names and tags of all tagged
 // declarations and pass them to a Go template as a collection.
-// The outputPath parameter determines the path of the output file.
+// The outputPath parameter determines the path of the output file. If formatGoCode is not set,
+// the output is formatted according to its file extension, see gotransform.RegisterFormatter.
 func NewCollectionTemplater(outputPath string, template *template.Template, formatGoCode bool) *CollectionTemplater {
 	return &CollectionTemplater{
 		outputPath:   outputPath,
@@ -25,12 +26,21 @@ type CollectionTemplater struct {
 	outputPath   string
 	formatGoCode bool
 	delay        bool
+	splitNaming  gotransform.NamingConvention
 }
 
 func (ct *CollectionTemplater) Delay() {
 	ct.delay = true
 }
 
+// Split makes the templater split the generated Go code into one file per declared type,
+// see gotransform.SplitFile. The files are placed next to the output path and named by
+// applying the naming convention to the type names. This only applies when formatting
+// Go code.
+func (ct *CollectionTemplater) Split(naming gotransform.NamingConvention) {
+	ct.splitNaming = naming
+}
+
 func (ct *CollectionTemplater) BeginFile(context tagproc.TagContext) error  { return nil }
 func (ct *CollectionTemplater) FinishFile(context tagproc.TagContext) error { return nil }
 
@@ -46,8 +56,11 @@ func (ct *CollectionTemplater) Finalize() error {
 }
 
 func (ct *CollectionTemplater) WriteTemplate() error {
+	if ct.formatGoCode && ct.splitNaming != nil {
+		return gotransform.WriteGoTemplateSplitWithOptions(ct.outputPath, ct.template, ct.entries, ct.splitNaming, ct.writeOptions())
+	}
 	if ct.formatGoCode {
-		return gotransform.WriteGoTemplate(ct.outputPath, ct.template, ct.entries)
+		return gotransform.WriteGoTemplateWithOptions(ct.outputPath, ct.template, ct.entries, ct.writeOptions())
 	} else {
 		return gotransform.WriteTemplate(ct.outputPath, ct.template, ct.entries)
 	}
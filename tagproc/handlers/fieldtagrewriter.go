@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"go/ast"
+
+	"github.com/chasingcarrots/gotransform"
+	"github.com/chasingcarrots/gotransform/tagproc"
+
+	"github.com/pkg/errors"
+)
+
+// NewFieldTagRewriter creates a tag handler that rewrites the field tags of all structs
+// that the target tag appears on, see gotransform.RewriteStructTags. For example,
+//     NewFieldTagRewriter(gotransform.AddFieldTag("json", gotransform.SnakeCase, "omitempty"))
+// adds json tags to all fields of the tagged structs.
+func NewFieldTagRewriter(rewrite gotransform.FieldTagRewriter) *FieldTagRewriter {
+	return &FieldTagRewriter{rewrite: rewrite}
+}
+
+type FieldTagRewriter struct {
+	rewrite gotransform.FieldTagRewriter
+}
+
+func (_ *FieldTagRewriter) BeginFile(context tagproc.TagContext) error  { return nil }
+func (_ *FieldTagRewriter) FinishFile(context tagproc.TagContext) error { return nil }
+func (_ *FieldTagRewriter) Finalize() error                             { return nil }
+
+func (ftr *FieldTagRewriter) HandleTag(context tagproc.TagContext, obj *ast.Object, tagLiteral string) error {
+	typeSpec := obj.Decl.(*ast.TypeSpec)
+	struc, ok := typeSpec.Type.(*ast.StructType)
+	if !ok {
+		return errors.Errorf("The tagged object is no struct! Object name: %s", obj.Name)
+	}
+	return gotransform.RewriteStructTags(obj.Name, struc, ftr.rewrite)
+}
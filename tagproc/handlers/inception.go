@@ -56,8 +56,14 @@ func (ct *InceptionTemplater) Finalize() error {
 	return nil
 }
 
+// PerformInception writes the generated program and runs it. Since the program is run by
+// the go command, it has to be written to the file system; it is an error if another
+// output is set, see gotransform.SetOutput.
 func (ct *InceptionTemplater) PerformInception() error {
-	if err := gotransform.WriteGoTemplate(ct.outputPath, ct.template, ct.entries); err != nil {
+	if output := gotransform.CurrentOutput(); output != gotransform.FileSystem {
+		return errors.Errorf("InceptionTemplater: Cannot run %s, as it is written to %T instead of the file system", ct.outputPath, output)
+	}
+	if err := gotransform.WriteGoTemplateWithOptions(ct.outputPath, ct.template, ct.entries, ct.writeOptions()); err != nil {
 		return err
 	}
 
@@ -69,7 +75,7 @@ func (ct *InceptionTemplater) PerformInception() error {
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
 	if err := cmd.Run(); err != nil {
-		return errors.Wrapf(err, "Inception cmd.Run() failed with stderr: %s\nstdout:", stderr.String(), stdout.String())
+		return errors.Wrapf(err, "Inception cmd.Run() failed with stderr: %s\nstdout: %s", stderr.String(), stdout.String())
 	}
 	fmt.Println(stdout.String())
 	return nil
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"text/template"
+
+	"github.com/chasingcarrots/gotransform"
+)
+
+func TestInceptionTemplater(t *testing.T) {
+	program := template.Must(template.New("main").Parse("package main\n\nfunc main() { fmt.Print(len(os.Args)) }\n"))
+	tests := []struct {
+		name   string
+		output func(dir string) gotransform.Output
+		err    string
+	}{
+		{
+			name:   "file system",
+			output: func(string) gotransform.Output { return gotransform.FileSystem },
+		},
+		{
+			name: "archive",
+			output: func(dir string) gotransform.Output {
+				return gotransform.NewArchiveOutput(filepath.Join(dir, "gen.zip"), gotransform.ArchiveZip, dir)
+			},
+			err: "as it is written to *gotransform.ArchiveOutput instead of the file system",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			gotransform.SetOutput(test.output(dir))
+			defer gotransform.SetOutput(gotransform.FileSystem)
+			inception := NewInceptionTemplater(filepath.Join(dir, "main.go"), program, "a", "b")
+			err := inception.Finalize()
+			if test.err == "" && err != nil {
+				t.Fatal(err)
+			}
+			if test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)) {
+				t.Errorf("Expected an error containing %q, got %v", test.err, err)
+			}
+		})
+	}
+}
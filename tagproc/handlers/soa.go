@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"bytes"
+	"go/ast"
+	"go/format"
+	"text/template"
+
+	"github.com/chasingcarrots/gotransform"
+	"github.com/chasingcarrots/gotransform/tagproc"
+
+	"github.com/pkg/errors"
+)
+
+// NewSoAGenerator creates a tag handler that converts the array-of-structs layout of the
+// tagged structs into a struct-of-arrays layout: for a tagged struct X, it adds a type
+// X<suffix> to the same file that stores each field of X in its own slice. The generated
+// type comes with the methods Len, Append, Get, Set, Swap and Each, so it can be used much
+// like a []X. For example, with the suffix "Slice",
+//     type Particle struct {
+//         tags.SoA
+//         Pos, Vel Vec3
+//     }
+// yields a type ParticleSlice with the fields Pos and Vel of type []Vec3.
+// The code is added once the file has been processed, see gotransform.AppendDeclarations.
+func NewSoAGenerator(suffix string) *SoAGenerator {
+	return &SoAGenerator{suffix: suffix}
+}
+
+type SoAGenerator struct {
+	suffix string
+	// generated holds the code generated for the current file
+	generated bytes.Buffer
+}
+
+func (sg *SoAGenerator) BeginFile(context tagproc.TagContext) error {
+	sg.generated.Reset()
+	return nil
+}
+
+func (sg *SoAGenerator) FinishFile(context tagproc.TagContext) error {
+	if sg.generated.Len() == 0 {
+		return nil
+	}
+	err := gotransform.AppendDeclarations(context.FileSet, context.File, sg.generated.Bytes())
+	sg.generated.Reset()
+	return errors.Wrap(err, "SoAGenerator: Failed to add generated code")
+}
+
+func (_ *SoAGenerator) Finalize() error { return nil }
+
+type soaField struct {
+	Name string
+	Type string
+}
+
+type soaData struct {
+	Name   string
+	Slice  string
+	Fields []soaField
+}
+
+// soaMethods are the names of the methods generated for the slice type; fields of the same
+// name would clash with them.
+var soaMethods = map[string]bool{"Len": true, "Append": true, "Get": true, "Set": true, "Swap": true, "Each": true}
+
+var soaTemplate = template.Must(template.New("soa").Parse(`
+// {{.Slice}} stores {{.Name}} values with each field in a separate slice.
+type {{.Slice}} struct {
+{{- range .Fields}}
+	{{.Name}} []{{.Type}}
+{{- end}}
+}
+
+// Len returns the number of elements in the slice.
+func (s *{{.Slice}}) Len() int {
+	return len(s.{{(index .Fields 0).Name}})
+}
+
+// Append adds an element to the end of the slice.
+func (s *{{.Slice}}) Append(value {{.Name}}) {
+{{- range .Fields}}
+	s.{{.Name}} = append(s.{{.Name}}, value.{{.Name}})
+{{- end}}
+}
+
+// Get assembles the element at the given index.
+func (s *{{.Slice}}) Get(i int) {{.Name}} {
+	return {{.Name}}{
+{{- range .Fields}}
+		{{.Name}}: s.{{.Name}}[i],
+{{- end}}
+	}
+}
+
+// Set overwrites the element at the given index.
+func (s *{{.Slice}}) Set(i int, value {{.Name}}) {
+{{- range .Fields}}
+	s.{{.Name}}[i] = value.{{.Name}}
+{{- end}}
+}
+
+// Swap exchanges the elements at the given indices.
+func (s *{{.Slice}}) Swap(i, j int) {
+{{- range .Fields}}
+	s.{{.Name}}[i], s.{{.Name}}[j] = s.{{.Name}}[j], s.{{.Name}}[i]
+{{- end}}
+}
+
+// Each calls f for every element of the slice, in order.
+func (s *{{.Slice}}) Each(f func(i int, value {{.Name}})) {
+	for i, n := 0, s.Len(); i < n; i++ {
+		f(i, s.Get(i))
+	}
+}
+`))
+
+func (sg *SoAGenerator) HandleTag(context tagproc.TagContext, obj *ast.Object, tagLiteral string) error {
+	typeSpec := obj.Decl.(*ast.TypeSpec)
+	struc, ok := typeSpec.Type.(*ast.StructType)
+	if !ok {
+		return errors.Errorf("The tagged object is no struct! Object name: %s", obj.Name)
+	}
+	if typeSpec.TypeParams != nil {
+		return errors.Errorf("SoAGenerator: Generic structs are not supported. Object name: %s", obj.Name)
+	}
+	data := soaData{Name: obj.Name, Slice: obj.Name + sg.suffix}
+	for _, field := range struc.Fields.List {
+		var typ bytes.Buffer
+		if err := format.Node(&typ, context.FileSet, field.Type); err != nil {
+			return errors.Wrapf(err, "SoAGenerator: Failed to print field type in %s", obj.Name)
+		}
+		names := make([]string, 0, len(field.Names))
+		for _, name := range field.Names {
+			names = append(names, name.Name)
+		}
+		if len(names) == 0 {
+			name := gotransform.EmbeddedFieldName(field.Type)
+			if name == "" {
+				return errors.Errorf("SoAGenerator: Unsupported embedded field in %s", obj.Name)
+			}
+			names = append(names, name)
+		}
+		for _, name := range names {
+			if name == "_" {
+				continue
+			}
+			if soaMethods[name] {
+				return errors.Errorf("SoAGenerator: Field %s of %s clashes with a generated method", name, obj.Name)
+			}
+			data.Fields = append(data.Fields, soaField{name, typ.String()})
+		}
+	}
+	if len(data.Fields) == 0 {
+		return errors.Errorf("SoAGenerator: %s has no fields", obj.Name)
+	}
+
+	if err := soaTemplate.Execute(&sg.generated, data); err != nil {
+		return errors.Wrapf(err, "SoAGenerator: Failed to generate code for %s", obj.Name)
+	}
+	return nil
+}
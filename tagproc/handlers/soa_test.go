@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"bytes"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+
+	"github.com/chasingcarrots/gotransform"
+	"github.com/chasingcarrots/gotransform/tagproc"
+)
+
+func TestSoAGenerator(t *testing.T) {
+	const header = "package p\n\nimport \"example.com/game/tags\"\n\n"
+	tests := []struct {
+		name string
+		src  string
+		want string
+		err  string
+	}{
+		{
+			name: "fields",
+			src:  header + "// Particle is a particle.\ntype Particle struct {\n\ttags.SoA\n\tPos, Vel Vec3 // in meters\n\t*Body\n\tList[int]\n\t_ int\n}\n",
+			want: `package p
+
+import "example.com/game/tags"
+
+// Particle is a particle.
+type Particle struct {
+	Pos, Vel Vec3 // in meters
+	*Body
+	List[int]
+	_ int
+}
+
+// ParticleSlice stores Particle values with each field in a separate slice.
+type ParticleSlice struct {
+	Pos  []Vec3
+	Vel  []Vec3
+	Body []*Body
+	List []List[int]
+}
+
+// Len returns the number of elements in the slice.
+func (s *ParticleSlice) Len() int {
+	return len(s.Pos)
+}
+
+// Append adds an element to the end of the slice.
+func (s *ParticleSlice) Append(value Particle) {
+	s.Pos = append(s.Pos, value.Pos)
+	s.Vel = append(s.Vel, value.Vel)
+	s.Body = append(s.Body, value.Body)
+	s.List = append(s.List, value.List)
+}
+
+// Get assembles the element at the given index.
+func (s *ParticleSlice) Get(i int) Particle {
+	return Particle{
+		Pos:  s.Pos[i],
+		Vel:  s.Vel[i],
+		Body: s.Body[i],
+		List: s.List[i],
+	}
+}
+
+// Set overwrites the element at the given index.
+func (s *ParticleSlice) Set(i int, value Particle) {
+	s.Pos[i] = value.Pos
+	s.Vel[i] = value.Vel
+	s.Body[i] = value.Body
+	s.List[i] = value.List
+}
+
+// Swap exchanges the elements at the given indices.
+func (s *ParticleSlice) Swap(i, j int) {
+	s.Pos[i], s.Pos[j] = s.Pos[j], s.Pos[i]
+	s.Vel[i], s.Vel[j] = s.Vel[j], s.Vel[i]
+	s.Body[i], s.Body[j] = s.Body[j], s.Body[i]
+	s.List[i], s.List[j] = s.List[j], s.List[i]
+}
+
+// Each calls f for every element of the slice, in order.
+func (s *ParticleSlice) Each(f func(i int, value Particle)) {
+	for i, n := 0, s.Len(); i < n; i++ {
+		f(i, s.Get(i))
+	}
+}
+`,
+		},
+		{
+			name: "clashing field",
+			src:  header + "type T struct {\n\ttags.SoA\n\tLen int\n}\n",
+			err:  "Field Len of T clashes with a generated method",
+		},
+		{
+			name: "no fields",
+			src:  header + "type T struct {\n\ttags.SoA\n}\n",
+			err:  "T has no fields",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fset := token.NewFileSet()
+			file, err := parser.ParseFile(fset, "p.go", test.src, parser.ParseComments)
+			if err != nil {
+				t.Fatal(err)
+			}
+			decls := append([]ast.Decl(nil), file.Decls...)
+			tp := tagproc.New()
+			tp.AddHandler("example.com/game/tags/SoA", NewSoAGenerator("Slice"))
+			err = tp.Apply(gotransform.FileContext{File: file, FileSet: fset, RelativePath: "p.go"})
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Errorf("Expected an error containing %q, got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			// the existing declarations must stay valid for other handlers
+			for i, decl := range decls {
+				if file.Decls[i] != decl {
+					t.Errorf("Declaration %d was replaced", i)
+				}
+			}
+			var buf bytes.Buffer
+			if err := format.Node(&buf, fset, file); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != test.want {
+				t.Errorf("Unexpected result.\ngot:\n%s\nwant:\n%s", got, test.want)
+			}
+		})
+	}
+}
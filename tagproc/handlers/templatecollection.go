@@ -3,6 +3,7 @@ package handlers
 import (
 	"go/ast"
 
+	"github.com/chasingcarrots/gotransform"
 	"github.com/chasingcarrots/gotransform/tagparser"
 	"github.com/chasingcarrots/gotransform/tagproc"
 )
@@ -10,6 +11,7 @@ import (
 type templateCollection struct {
 	entries        []templateEntry
 	templateMapper func(*templateEntry)
+	goWriteOptions *gotransform.GoWriteOptions
 }
 
 type templateEntry struct {
@@ -26,6 +28,19 @@ func (tc *templateCollection) SetTemplateMapper(mapper func(*templateEntry)) {
 	tc.templateMapper = mapper
 }
 
+// SetGoWriteOptions sets the options used to format and write the generated Go code. By
+// default, gotransform.DefaultGoWriteOptions are used.
+func (tc *templateCollection) SetGoWriteOptions(options gotransform.GoWriteOptions) {
+	tc.goWriteOptions = &options
+}
+
+func (tc *templateCollection) writeOptions() gotransform.GoWriteOptions {
+	if tc.goWriteOptions != nil {
+		return *tc.goWriteOptions
+	}
+	return gotransform.DefaultGoWriteOptions()
+}
+
 func (tc *templateCollection) addEntry(context tagproc.TagContext, obj *ast.Object, tagLiteral string) error {
 	entry, err := makeTemplateEntry(context, obj, tagLiteral)
 	if err != nil {
@@ -13,7 +13,8 @@ import (
 // struct or interface with the declared name and its tags as the template's argument. The parameters
 // outputPath and makeName control the path to write the instantiated templates to and how
 // the declared name of a struct or interface corresponds to the file name. The template
-// parameter specifies the template that should be instantiated.
+// parameter specifies the template that should be instantiated. If formatGoCode is not set,
+// the output is formatted according to its file extension, see gotransform.RegisterFormatter.
 func NewTemplater(outputPath string, template *template.Template, makeName func(string) string, formatGoCode bool) *Templater {
 	return &Templater{
 		outputPath:   outputPath,
@@ -58,7 +59,7 @@ func (t *Templater) WriteTemplates() error {
 		name := t.makeName(entry.Name)
 		output := filepath.Join(t.outputPath, name)
 		if t.formatGoCode {
-			if err := gotransform.WriteGoTemplate(output, t.template, entry); err != nil {
+			if err := gotransform.WriteGoTemplateWithOptions(output, t.template, entry, t.writeOptions()); err != nil {
 				return err
 			}
 		} else {
@@ -0,0 +1,227 @@
+package tagproc
+
+import (
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"strings"
+
+	"github.com/chasingcarrots/gotransform"
+)
+
+// HasTagsSuffix accepts all import paths ending in "tags" as tag packages. It is used when
+// TagProcessor.TagPackages is not set. Note that it also accepts packages that are not
+// meant to contain tags, such as github.com/foo/hashtags; use TagPackageSet to avoid this.
+func HasTagsSuffix(importPath string) bool {
+	return strings.HasSuffix(importPath, "tags")
+}
+
+// TagPackageSet returns a predicate for TagProcessor.TagPackages that accepts exactly the
+// given import paths.
+func TagPackageSet(importPaths ...string) func(importPath string) bool {
+	set := make(map[string]bool, len(importPaths))
+	for _, path := range importPaths {
+		set[path] = true
+	}
+	return func(importPath string) bool {
+		return set[importPath]
+	}
+}
+
+// packageInfo is what the tag processor needs to know about an imported package.
+type packageInfo struct {
+	name string
+	// typeNames contains the names of the types declared at the top level of the package.
+	typeNames map[string]bool
+}
+
+// packageResolver reads imported packages to find their real names and the types they
+// declare. The results are cached, including failures.
+type packageResolver struct {
+	packages map[string]*packageInfo
+	// localPaths caches the import paths of the packages that the processed files belong to.
+	localPaths map[string]string
+}
+
+// resolve finds the source of the package with the given import path as seen from the
+// directory srcDir. It returns nil if the package cannot be found or read; tag packages
+// do not need to exist, as long as they are named like the last element of their path.
+func (pr *packageResolver) resolve(importPath, srcDir string) *packageInfo {
+	if pr.packages == nil {
+		pr.packages = make(map[string]*packageInfo)
+	}
+	key := srcDir + "\x00" + importPath
+	if info, ok := pr.packages[key]; ok {
+		return info
+	}
+	// in module mode, the go command looks up packages relative to the context's directory
+	context := build.Default
+	context.Dir = srcDir
+	var info *packageInfo
+	if pkg, err := context.Import(importPath, srcDir, 0); err == nil {
+		info = &packageInfo{name: pkg.Name, typeNames: make(map[string]bool)}
+		fileSet := token.NewFileSet()
+		for _, name := range pkg.GoFiles {
+			f, err := parser.ParseFile(fileSet, filepath.Join(pkg.Dir, name), nil, parser.SkipObjectResolution)
+			if err != nil {
+				continue
+			}
+			for _, decl := range f.Decls {
+				if genDecl, ok := decl.(*ast.GenDecl); ok && genDecl.Tok == token.TYPE {
+					for _, spec := range genDecl.Specs {
+						info.typeNames[spec.(*ast.TypeSpec).Name.Name] = true
+					}
+				}
+			}
+		}
+	}
+	pr.packages[key] = info
+	return info
+}
+
+// localPath returns the import path of the package with the given name in the directory
+// srcDir. Like the type checker, it falls back to the package name outside of a module, so
+// that local tag types get the same names with and without type information when
+// TagProcessor.TagPackages is set.
+func (pr *packageResolver) localPath(srcDir, packageName string) string {
+	if pr.localPaths == nil {
+		pr.localPaths = make(map[string]string)
+	}
+	key := srcDir + "\x00" + packageName
+	if importPath, ok := pr.localPaths[key]; ok {
+		return importPath
+	}
+	importPath, err := gotransform.ImportPathForDir(srcDir)
+	if err != nil {
+		importPath = packageName
+	}
+	pr.localPaths[key] = importPath
+	return importPath
+}
+
+// tagResolver decides which of the embedded types in a file are tags.
+type tagResolver struct {
+	// imports maps the names of the imported packages to their paths.
+	imports map[string]string
+	// dotImports contains the paths of the tag packages imported with a dot.
+	dotImports   []string
+	isTagPackage func(importPath string) bool
+	info         *types.Info
+	// pkg is the package of the file, if type information is available.
+	pkg      *types.Package
+	packages *packageResolver
+	srcDir   string
+}
+
+// newTagResolver collects the imports of a file. The names of imported tag packages are
+// taken from the type information if available, and otherwise read from the packages
+// themselves; all other packages are assumed to be named like the last element of their
+// import path.
+func (tp *TagProcessor) newTagResolver(fileContext gotransform.FileContext) *tagResolver {
+	if tp.packages == nil {
+		tp.packages = &packageResolver{}
+	}
+	resolver := &tagResolver{
+		imports:      make(map[string]string),
+		isTagPackage: tp.TagPackages,
+		info:         fileContext.Info,
+		pkg:          fileContext.Package,
+		packages:     tp.packages,
+	}
+	if resolver.isTagPackage == nil {
+		resolver.isTagPackage = HasTagsSuffix
+	}
+	if file := fileContext.FileSet.File(fileContext.File.Pos()); file != nil {
+		resolver.srcDir = filepath.Dir(file.Name())
+	}
+	realNames := make(map[string]string)
+	if fileContext.Package != nil {
+		for _, pkg := range fileContext.Package.Imports() {
+			// the type checker makes up incomplete packages for imports that it failed to
+			// read, guessing their names from the path
+			if pkg.Complete() {
+				realNames[pkg.Path()] = pkg.Name()
+			}
+		}
+	}
+
+	for _, spec := range fileContext.File.Imports {
+		path, name := extractImport(spec)
+		if spec.Name == nil {
+			if realName, ok := realNames[path]; ok {
+				name = realName
+			} else if resolver.isTagPackage(path) {
+				if info := resolver.packages.resolve(path, resolver.srcDir); info != nil {
+					name = info.name
+				}
+			}
+		}
+		if name == "." && resolver.isTagPackage(path) {
+			resolver.dotImports = append(resolver.dotImports, path)
+		}
+		resolver.imports[name] = path
+	}
+	// tag packages given by import path also name the local tag types by the import path
+	switch {
+	case tp.TagPackages == nil:
+		resolver.imports[""] = fileContext.File.Name.Name
+	case fileContext.Package != nil:
+		resolver.imports[""] = fileContext.Package.Path()
+	default:
+		resolver.imports[""] = resolver.packages.localPath(resolver.srcDir, fileContext.File.Name.Name)
+	}
+	return resolver
+}
+
+// findTag checks whether an embedded type is a tag and returns its full name, i.e. the
+// import path of its package and its name joined by a slash.
+func (tr *tagResolver) findTag(expr ast.Expr) (isTag bool, typ string) {
+	path := parseSelector(expr)
+	if len(path) == 0 {
+		return false, ""
+	}
+	if tr.info != nil {
+		ident, ok := expr.(*ast.Ident)
+		if sel, isSel := expr.(*ast.SelectorExpr); isSel {
+			ident, ok = sel.Sel, true
+		}
+		if ok {
+			if obj, found := tr.info.Uses[ident].(*types.TypeName); found && obj.Pkg() != nil {
+				importPath := obj.Pkg().Path()
+				if obj.Pkg() == tr.pkg {
+					importPath = tr.imports[""]
+				}
+				return tr.isTagPackage(importPath), importPath + "/" + obj.Name()
+			}
+		}
+	}
+	if len(path) > 1 {
+		// selector expression
+		importPath, ok := tr.imports[path[0]]
+		if !ok {
+			return false, ""
+		}
+		return tr.isTagPackage(importPath), importPath + "/" + strings.Join(path[1:], "/")
+	}
+	// just an identifier, which may come from a dot-import
+	if importPath, ok := tr.findDotImport(path[0]); ok {
+		return true, importPath + "/" + path[0]
+	}
+	importPath := tr.imports[""]
+	return tr.isTagPackage(importPath), importPath + "/" + path[0]
+}
+
+// findDotImport looks for a dot-imported tag package that declares the given type. Such
+// packages have to be readable, otherwise there is no way to tell their types apart from
+// the types of the current package.
+func (tr *tagResolver) findDotImport(name string) (string, bool) {
+	for _, importPath := range tr.dotImports {
+		if info := tr.packages.resolve(importPath, tr.srcDir); info != nil && info.typeNames[name] {
+			return importPath, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,148 @@
+package tagproc
+
+import (
+	"go/ast"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/chasingcarrots/gotransform"
+)
+
+// typeRecorder records the tag types and the names of the declarations they appear on.
+type typeRecorder struct {
+	tagType string
+	handled *[]string
+}
+
+func (tr typeRecorder) BeginFile(context TagContext) error  { return nil }
+func (tr typeRecorder) FinishFile(context TagContext) error { return nil }
+func (tr typeRecorder) Finalize() error                     { return nil }
+
+func (tr typeRecorder) HandleTag(context TagContext, obj *ast.Object, literalTag string) error {
+	*tr.handled = append(*tr.handled, obj.Name+" "+tr.tagType)
+	return nil
+}
+
+func TestTagPackages(t *testing.T) {
+	module := map[string]string{
+		"go.mod":              "module example.com/game\n",
+		"tags/tags.go":        "package tags\n\ntype CollectMe interface{}\n",
+		"hashtags/hashtag.go": "package hashtags\n\ntype CollectMe interface{}\n",
+		"mytags/mytags.go":    "package mytags\n\ntype CollectMe interface{}\n",
+		"tagdefs/markers.go":  "package markers\n\ntype Mark interface{}\n",
+		"util/tags/tags.go":   "package tags\n\ntype Util interface{}\n",
+	}
+	tagTypes := []string{
+		"example.com/game/tags/CollectMe",
+		"example.com/game/hashtags/CollectMe",
+		"example.com/game/mytags/CollectMe",
+		"example.com/game/tagdefs/Mark",
+		"example.com/game/util/tags/Util",
+		"example.com/game/p/Local",
+		"localtags/Local",
+	}
+	tests := []struct {
+		name        string
+		src         string
+		tagPackages func(importPath string) bool
+		want        []string
+	}{
+		{
+			name: "default tag packages",
+			src: "package p\n\nimport (\n\t\"example.com/game/hashtags\"\n\t\"example.com/game/mytags\"\n\t\"example.com/game/tags\"\n\tutil \"example.com/game/util/tags\"\n)\n\n" +
+				"type A struct {\n\ttags.CollectMe\n}\n\ntype B struct {\n\thashtags.CollectMe\n}\n\ntype C interface {\n\tutil.Util\n}\n\ntype D struct {\n\tmytags.CollectMe\n}\n",
+			want: []string{
+				"A example.com/game/tags/CollectMe",
+				"B example.com/game/hashtags/CollectMe",
+				"C example.com/game/util/tags/Util",
+				"D example.com/game/mytags/CollectMe",
+			},
+		},
+		{
+			name:        "explicit set",
+			src:         "package p\n\nimport (\n\t\"example.com/game/hashtags\"\n\t\"example.com/game/mytags\"\n\t\"example.com/game/tags\"\n)\n\ntype A struct {\n\ttags.CollectMe\n}\n\ntype B struct {\n\thashtags.CollectMe\n}\n\ntype D struct {\n\tmytags.CollectMe\n}\n",
+			tagPackages: TagPackageSet("example.com/game/hashtags"),
+			want:        []string{"B example.com/game/hashtags/CollectMe"},
+		},
+		{
+			name:        "package name differs from path",
+			src:         "package p\n\nimport \"example.com/game/tagdefs\"\n\ntype A struct {\n\tmarkers.Mark\n}\n",
+			tagPackages: TagPackageSet("example.com/game/tagdefs"),
+			want:        []string{"A example.com/game/tagdefs/Mark"},
+		},
+		{
+			name:        "dot import",
+			src:         "package p\n\nimport . \"example.com/game/tagdefs\"\n\ntype Other struct{}\n\ntype A struct {\n\tMark\n\tOther\n}\n",
+			tagPackages: TagPackageSet("example.com/game/tagdefs"),
+			want:        []string{"A example.com/game/tagdefs/Mark"},
+		},
+		{
+			name: "local tag type named by the package name",
+			src:  "package localtags\n\ntype Local interface{}\n\ntype A struct {\n\tLocal\n}\n",
+			want: []string{"A localtags/Local"},
+		},
+		{
+			name:        "local tag type named by the import path",
+			src:         "package p\n\ntype Local interface{}\n\ntype A struct {\n\tLocal\n}\n",
+			tagPackages: TagPackageSet("example.com/game/p"),
+			want:        []string{"A example.com/game/p/Local"},
+		},
+	}
+	for _, test := range tests {
+		for _, typeCheck := range []bool{false, true} {
+			name := test.name
+			if typeCheck {
+				name += " with type check"
+			}
+			t.Run(name, func(t *testing.T) {
+				dir := t.TempDir()
+				files := map[string]string{"p/p.go": test.src}
+				for name, content := range module {
+					files[name] = content
+				}
+				for name, content := range files {
+					path := filepath.Join(dir, filepath.FromSlash(name))
+					if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+						t.Fatal(err)
+					}
+					if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+						t.Fatal(err)
+					}
+				}
+				var handled []string
+				tp := New()
+				tp.TagPackages = test.tagPackages
+				for _, tagType := range tagTypes {
+					tp.AddHandler(tagType, typeRecorder{tagType, &handled})
+				}
+				options := gotransform.Options{TypeCheck: typeCheck}
+				if err := gotransform.ApplyWithOptions(filepath.Join(dir, "p"), []gotransform.FileTransformation{tp}, options); err != nil {
+					t.Fatal(err)
+				}
+				sort.Strings(handled)
+				if strings.Join(handled, "\n") != strings.Join(test.want, "\n") {
+					t.Errorf("Expected the tags %q, got %q", test.want, handled)
+				}
+			})
+		}
+	}
+}
+
+func TestHasTagsSuffix(t *testing.T) {
+	tests := map[string]bool{
+		"tags":                     true,
+		"github.com/foo/tags":      true,
+		"github.com/foo/hashtags":  true,
+		"github.com/foo/mytags":    true,
+		"github.com/foo/tags/more": false,
+		"github.com/foo/bar":       false,
+	}
+	for importPath, want := range tests {
+		if got := HasTagsSuffix(importPath); got != want {
+			t.Errorf("Expected %v for %s, got %v", want, importPath, got)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package tagproc
+
+import (
+	"fmt"
+	"go/scanner"
+	"go/token"
+	"reflect"
+	"strings"
+
+	"github.com/chasingcarrots/gotransform/tagparser"
+)
+
+// SchemaError is returned when struct tags do not satisfy their schemas. It lists all
+// problems of all files, sorted by position.
+type SchemaError struct {
+	Diagnostics scanner.ErrorList
+}
+
+func (se *SchemaError) Error() string {
+	lines := make([]string, len(se.Diagnostics))
+	for i, diagnostic := range se.Diagnostics {
+		lines[i] = diagnostic.Error()
+	}
+	return "Invalid struct tags:\n" + strings.Join(lines, "\n")
+}
+
+// SetSchema registers the schema that the struct tags of the given tag type have to satisfy.
+// The schema is a struct (or a pointer to one) describing the keys like for tagparser.Unmarshal,
+// including the allowed values, required keys and groups of mutually exclusive keys. For
+// example,
+//     type collectOptions struct {
+//         Name  string `tag:"name,required"`
+//         Size  int
+//         Fast  bool `tag:"fast,exclusive=speed"`
+//         Small bool `tag:"small,exclusive=speed"`
+//     }
+//     tp.SetSchema("github.com/foo/tags/CollectMe", collectOptions{})
+// only accepts tags like `name:"items" size:"4"` on tags.CollectMe. Handlers can decode the
+// tag into the same struct with tagparser.Unmarshal.
+// When the tag processor runs as part of gotransform.Apply, the tags of all files are
+// validated before any handler runs, and all problems are reported together as a
+// SchemaError with the positions of the offending tags.
+func (tp *TagProcessor) SetSchema(tag string, schema interface{}) {
+	if tp.schemas == nil {
+		tp.schemas = make(map[string]reflect.Type)
+	}
+	typ := reflect.TypeOf(schema)
+	if typ != nil && typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	tp.schemas[tag] = typ
+}
+
+// validate checks the struct tags of the tagged declarations in a file against the registered
+// schemas and adds a diagnostic for each invalid tag.
+func (tp *TagProcessor) validate(fileSet *token.FileSet, taggedTypes []taggedDeclaration, diagnostics *scanner.ErrorList) {
+	for _, decl := range taggedTypes {
+		schema, ok := tp.schemas[decl.TagType]
+		if !ok {
+			continue
+		}
+		if err := validateTag(schema, decl.LiteralTag); err != nil {
+			msg := fmt.Sprintf("Invalid tag %s on %s: %v", decl.TagType, decl.Object.Name, err)
+			diagnostics.Add(fileSet.Position(decl.Pos), msg)
+		}
+	}
+}
+
+// schemaError turns the diagnostics into a SchemaError, if there are any.
+func schemaError(diagnostics scanner.ErrorList) error {
+	if len(diagnostics) == 0 {
+		return nil
+	}
+	diagnostics.Sort()
+	return &SchemaError{diagnostics}
+}
+
+// validateTag decodes a struct tag into a new value of the schema type.
+func validateTag(schema reflect.Type, literalTag string) error {
+	if schema == nil || schema.Kind() != reflect.Struct {
+		return fmt.Errorf("The schema must be a struct, got %v", schema)
+	}
+	return tagparser.Unmarshal(literalTag, reflect.New(schema).Interface())
+}
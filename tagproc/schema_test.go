@@ -0,0 +1,144 @@
+package tagproc
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/chasingcarrots/gotransform"
+
+	"github.com/pkg/errors"
+)
+
+type collectOptions struct {
+	Name  string `tag:"name,required"`
+	Size  int
+	Mode  string `tag:"mode,enum=list|map"`
+	Fast  bool   `tag:"fast,exclusive=speed"`
+	Small bool   `tag:"small,exclusive=speed"`
+}
+
+// recordingHandler records the names of the tagged declarations it sees.
+type recordingHandler struct {
+	handled []string
+}
+
+func (rh *recordingHandler) BeginFile(context TagContext) error  { return nil }
+func (rh *recordingHandler) FinishFile(context TagContext) error { return nil }
+func (rh *recordingHandler) Finalize() error                     { return nil }
+
+func (rh *recordingHandler) HandleTag(context TagContext, obj *ast.Object, literalTag string) error {
+	rh.handled = append(rh.handled, obj.Name+" "+literalTag)
+	return nil
+}
+
+func TestSchema(t *testing.T) {
+	const header = "package p\n\nimport \"example.com/game/tags\"\n\n"
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  []string
+		err   []string
+	}{
+		{
+			name: "valid tags",
+			files: map[string]string{
+				"a.go": header + "type A struct {\n\ttags.CollectMe `name:\"a\" size:\"2\" fast:\"true\"`\n}\n",
+				"b.go": header + "type B struct {\n\ttags.Other `anything:\"goes\"`\n\ttags.CollectMe `name:\"b\" mode:\"map\"`\n}\n",
+			},
+			want: []string{`A name:"a" size:"2" fast:"true"`, `B name:"b" mode:"map"`},
+		},
+		{
+			name: "all files are checked before any handler runs",
+			files: map[string]string{
+				"a.go": header + "type A struct {\n\ttags.CollectMe `name:\"a\"`\n}\n",
+				"b.go": header + "type B struct {\n\ttags.CollectMe `size:\"x\"`\n}\n\ntype C struct {\n\tX int\n\ttags.CollectMe `name:\"c\" fast:\"true\" small:\"true\"`\n}\n",
+				"c.go": header + "type D interface {\n\ttags.CollectMe\n}\n\ntype E struct {\n\ttags.CollectMe `name:\"e\" mode:\"set\" color:\"red\"`\n}\n",
+			},
+			err: []string{
+				"b.go:6:2: Invalid tag example.com/game/tags/CollectMe on B: Unmarshal: Missing required key name",
+				"b.go:11:2: Invalid tag example.com/game/tags/CollectMe on C: Unmarshal: Keys fast, small are mutually exclusive",
+				"c.go:6:2: Invalid tag example.com/game/tags/CollectMe on D: Unmarshal: Missing required key name",
+				"c.go:10:2: Invalid tag example.com/game/tags/CollectMe on E: Unmarshal: Unknown key color, expected one of fast, mode, name, size, small",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range test.files {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			handler := &recordingHandler{}
+			tp := New()
+			tp.AddHandler("example.com/game/tags/CollectMe", handler)
+			tp.SetSchema("example.com/game/tags/CollectMe", &collectOptions{})
+			err := gotransform.Apply(dir, []gotransform.FileTransformation{tp})
+			if len(test.err) > 0 {
+				if _, ok := errors.Cause(err).(*SchemaError); !ok {
+					t.Fatalf("Expected a SchemaError, got %v", err)
+				}
+				lines := strings.Split(err.Error(), "\n")[1:]
+				if len(lines) != len(test.err) {
+					t.Fatalf("Expected %d diagnostics, got %v", len(test.err), err)
+				}
+				for i, want := range test.err {
+					if !strings.HasSuffix(filepath.ToSlash(lines[i]), "/"+want) {
+						t.Errorf("Expected a diagnostic ending in %q, got %q", want, lines[i])
+					}
+				}
+				if len(handler.handled) > 0 {
+					t.Errorf("Expected no handler to run, but it saw %v", handler.handled)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Join(handler.handled, "\n") != strings.Join(test.want, "\n") {
+				t.Errorf("Expected the handler to see %q, got %q", test.want, handler.handled)
+			}
+		})
+	}
+}
+
+func TestSchemaWithoutValidate(t *testing.T) {
+	src := "package p\n\nimport \"example.com/game/tags\"\n\ntype A struct {\n\ttags.CollectMe `size:\"2\"`\n}\n"
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "p.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := &recordingHandler{}
+	tp := New()
+	tp.AddHandler("example.com/game/tags/CollectMe", handler)
+	tp.SetSchema("example.com/game/tags/CollectMe", collectOptions{})
+	err = tp.Apply(gotransform.FileContext{File: file, FileSet: fset, RelativePath: "p.go"})
+	if want := "p.go:6:2: Invalid tag example.com/game/tags/CollectMe on A: Unmarshal: Missing required key name"; err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("Expected an error containing %q, got %v", want, err)
+	}
+	if len(handler.handled) > 0 {
+		t.Errorf("Expected no handler to run, but it saw %v", handler.handled)
+	}
+}
+
+func TestSchemaMustBeStruct(t *testing.T) {
+	src := "package p\n\nimport \"example.com/game/tags\"\n\ntype A struct {\n\ttags.CollectMe\n}\n"
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "p.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tp := New()
+	tp.SetSchema("example.com/game/tags/CollectMe", "name")
+	err = tp.Validate([]gotransform.FileContext{{File: file, FileSet: fset, RelativePath: "p.go"}})
+	if want := "The schema must be a struct, got string"; err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("Expected an error containing %q, got %v", want, err)
+	}
+}
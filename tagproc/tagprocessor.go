@@ -2,8 +2,10 @@ package tagproc
 
 import (
 	"go/ast"
+	"go/scanner"
 	"go/token"
-	"strings"
+	"reflect"
+	"strconv"
 
 	"github.com/chasingcarrots/gotransform"
 
@@ -30,22 +32,39 @@ type TagHandler interface {
 type TagContext struct {
 	File    *ast.File
 	FileSet *token.FileSet
-	// An import map that maps package scopes to the full package paths. This work under
-	// the assumption that a path github.com/foo/bar actually points to a package called
-	// bar.
+	// An import map that maps package scopes to the full package paths. The names of tag
+	// packages are resolved, all other packages are assumed to be called like the last
+	// element of their path unless type information is available, e.g. github.com/foo/bar
+	// is assumed to be called bar. The empty name maps to the name of the file's own package,
+	// or to its import path if TagProcessor.TagPackages is set.
 	Imports map[string]string
 }
 
 // HandlerMap maps path types to lists of tag handlers.
 type HandlerMap = map[string][]TagHandler
 
-// TagProcessor is the FileTransformation that handles all tags.
+// TagProcessor is the FileTransformation that handles all tags. Create it with New or with
+// a composite literal with keyed fields, such as
+//     &tagproc.TagProcessor{HandlerMap: tagproc.HandlerMap{...}}
+// since the TagProcessor has more fields than just the HandlerMap.
 type TagProcessor struct {
 	HandlerMap HandlerMap
+	// TagPackages decides which import paths contain tag types. If it is nil, HasTagsSuffix
+	// is used; see TagPackageSet for a precise alternative. When it is set, tag types declared
+	// in the processed package itself are named by its import path instead of its name, e.g.
+	// github.com/foo/bar/MyTag instead of bar/MyTag, or by the name outside of a module.
+	TagPackages func(importPath string) bool
+	schemas     map[string]reflect.Type
+	// validated is set when the schemas of all files were checked by Validate.
+	validated bool
+	packages  *packageResolver
 }
 
 func New() *TagProcessor {
-	return &TagProcessor{make(map[string][]TagHandler)}
+	return &TagProcessor{
+		HandlerMap: make(map[string][]TagHandler),
+		schemas:    make(map[string]reflect.Type),
+	}
 }
 
 // AddHandler adds a new handler for the given tag.
@@ -73,20 +92,48 @@ func (tp *TagProcessor) Finalize() error {
 	return nil
 }
 
-func (tp *TagProcessor) Prepare() error { return nil }
+func (tp *TagProcessor) Prepare() error {
+	tp.validated = false
+	return nil
+}
 
-// Apply goes through the given file, looks for structs with tags, and calls
-// all the respective tag processors on the structs.
+// Validate checks the struct tags of all files against the registered schemas, see SetSchema.
+func (tp *TagProcessor) Validate(collection []gotransform.FileContext) error {
+	var diagnostics scanner.ErrorList
+	for _, fileContext := range collection {
+		taggedTypes := tp.findTaggedTypes(fileContext.File.Scope, tp.newTagResolver(fileContext))
+		tp.validate(fileContext.FileSet, taggedTypes, &diagnostics)
+	}
+	if err := schemaError(diagnostics); err != nil {
+		return errors.Wrapf(err, "TagProcessor Validate")
+	}
+	tp.validated = true
+	return nil
+}
+
+// Apply goes through the given file, looks for structs with tags, and calls all the
+// respective tag processors on the structs. If the files were not checked by Validate
+// beforehand, the struct tags of this file are validated first.
 func (tp *TagProcessor) Apply(fileContext gotransform.FileContext) error {
+	resolver := tp.newTagResolver(fileContext)
 	context := TagContext{
 		File:    fileContext.File,
 		FileSet: fileContext.FileSet,
-		Imports: importMap(fileContext.File),
+		Imports: resolver.imports,
+	}
+	taggedTypes := tp.findTaggedTypes(context.File.Scope, resolver)
+	if !tp.validated {
+		var diagnostics scanner.ErrorList
+		tp.validate(context.FileSet, taggedTypes, &diagnostics)
+		if err := schemaError(diagnostics); err != nil {
+			return errors.Wrapf(err, "TagProcessor Apply/validate")
+		}
 	}
+	removeTags(taggedTypes)
 	if err := tp.beginFile(&context); err != nil {
 		return errors.Wrapf(err, "TagProcessor Apply/beginFile")
 	}
-	if err := tp.handleFile(&context); err != nil {
+	if err := tp.handleFile(&context, taggedTypes); err != nil {
 		return errors.Wrapf(err, "TagProcessor Apply/handleFile")
 	}
 	if err := tp.finishFile(&context); err != nil {
@@ -106,8 +153,7 @@ func (tp *TagProcessor) beginFile(context *TagContext) error {
 	return nil
 }
 
-func (tp *TagProcessor) handleFile(context *TagContext) error {
-	taggedTypes := tp.findTaggedTypes(context.File.Scope, context.Imports)
+func (tp *TagProcessor) handleFile(context *TagContext, taggedTypes []taggedDeclaration) error {
 	for _, s := range taggedTypes {
 		if handlers, ok := tp.HandlerMap[s.TagType]; ok {
 			for _, h := range handlers {
@@ -135,11 +181,17 @@ type taggedDeclaration struct {
 	TagType    string
 	LiteralTag string
 	Object     *ast.Object
+	// Pos is the position of the embedded tag type.
+	Pos token.Pos
+	// field is the embedded tag type in the list of fields or methods of the declaration.
+	field  *ast.Field
+	fields *ast.FieldList
 }
 
-// findTaggedTypes looks for all structs and interfaces within a given scope that have types from a 'tags' package
-// as an anonymous field, removes them, and passes them to specific tag handling functions.
-func (tp TagProcessor) findTaggedTypes(scope *ast.Scope, imports map[string]string) []taggedDeclaration {
+// findTaggedTypes looks for all structs and interfaces within a given scope that have types from a tag package
+// as an anonymous field, and passes them to specific tag handling functions. The tags stay
+// in the AST until they are removed with removeTags.
+func (tp TagProcessor) findTaggedTypes(scope *ast.Scope, resolver *tagResolver) []taggedDeclaration {
 	output := make([]taggedDeclaration, 0)
 	for _, obj := range scope.Objects {
 		if obj.Kind != ast.Typ {
@@ -148,85 +200,60 @@ func (tp TagProcessor) findTaggedTypes(scope *ast.Scope, imports map[string]stri
 		typeSpec := obj.Decl.(*ast.TypeSpec)
 		switch spec := typeSpec.Type.(type) {
 		case *ast.StructType:
-			tp.findStructTags(obj, spec, imports, &output)
+			tp.findStructTags(obj, spec, resolver, &output)
 		case *ast.InterfaceType:
-			tp.findInterfaceTags(obj, spec, imports, &output)
+			tp.findInterfaceTags(obj, spec, resolver, &output)
 		}
 	}
 	return output
 }
 
-func (tp TagProcessor) findStructTags(obj *ast.Object, struc *ast.StructType, imports map[string]string, output *[]taggedDeclaration) {
+func (tp TagProcessor) findStructTags(obj *ast.Object, struc *ast.StructType, resolver *tagResolver, output *[]taggedDeclaration) {
 	// find anonymous fields that are of tag-type
-	toRemove := make([]int, 0)
-	for i, f := range struc.Fields.List {
+	for _, f := range struc.Fields.List {
 		if f.Names != nil {
 			continue
 		}
-		isTag, typ := findTag(f.Type, imports)
+		isTag, typ := resolver.findTag(f.Type)
 		if !isTag {
 			continue
 		}
 		// get the struct field tag value
 		fieldTag := ""
 		if f.Tag != nil {
-			// remove the "" from the tag
-			fieldTag = f.Tag.Value[1 : len(f.Tag.Value)-1]
+			// remove the quotes from the tag; the parser made sure that it is a valid literal
+			fieldTag, _ = strconv.Unquote(f.Tag.Value)
 		}
-		toRemove = append(toRemove, i)
-		*output = append(*output, taggedDeclaration{typ, fieldTag, obj})
-	}
-	// NB: we can only delete afterwards because we don't want to screw with the iteration
-	for i := range toRemove {
-		removeTag(struc.Fields, toRemove[len(toRemove)-1-i])
+		*output = append(*output, taggedDeclaration{typ, fieldTag, obj, f.Pos(), f, struc.Fields})
 	}
 }
 
-func (tp TagProcessor) findInterfaceTags(obj *ast.Object, interfac *ast.InterfaceType, imports map[string]string, output *[]taggedDeclaration) {
+func (tp TagProcessor) findInterfaceTags(obj *ast.Object, interfac *ast.InterfaceType, resolver *tagResolver, output *[]taggedDeclaration) {
 	// find anonymous fields that are of tag-type
-	toRemove := make([]int, 0)
-	for i, f := range interfac.Methods.List {
+	for _, f := range interfac.Methods.List {
 		if f.Names != nil {
 			continue
 		}
-		isTag, typ := findTag(f.Type, imports)
+		isTag, typ := resolver.findTag(f.Type)
 		if !isTag {
 			continue
 		}
-		toRemove = append(toRemove, i)
 		// note that interface do not have struct-tags
-		*output = append(*output, taggedDeclaration{typ, "", obj})
-	}
-	// NB: we can only delete afterwards because we don't want to screw with the iteration
-	for i := range toRemove {
-		removeTag(interfac.Methods, toRemove[len(toRemove)-1-i])
+		*output = append(*output, taggedDeclaration{typ, "", obj, f.Pos(), f, interfac.Methods})
 	}
 }
 
-func findTag(expr ast.Expr, imports map[string]string) (isTag bool, typ string) {
-	path := parseSelector(expr)
-	if len(path) == 0 {
-		return false, ""
-	}
-	var importPath string
-	if len(path) == 1 {
-		// just an identifier
-		importPath = imports[""]
-		typ = importPath + "/" + path[0]
-	} else {
-		// selector expression
-		importPath = imports[path[0]]
-		typ = importPath + "/" + strings.Join(path[1:], "/")
+// removeTags removes the tag types from the structs and interfaces. The tag types are
+// embedded as anonymous fields in the structs.
+func removeTags(taggedTypes []taggedDeclaration) {
+	for _, decl := range taggedTypes {
+		for i, field := range decl.fields.List {
+			if field == decl.field {
+				decl.fields.List = append(decl.fields.List[:i], decl.fields.List[i+1:]...)
+				break
+			}
+		}
 	}
-	isTag = strings.HasSuffix(importPath, "tags")
-	return
-}
-
-// removeTag removes a tag-type from a struct. The tag type is embedded as an anonymous field
-// in the struct.
-func removeTag(fieldList *ast.FieldList, tagIndex int) {
-	fields := fieldList.List
-	fieldList.List = append(fields[:tagIndex], fields[tagIndex+1:]...)
 }
 
 // reverse reverses a slice of strings.
@@ -1,8 +1,6 @@
 package gotransform
 
 import (
-	"strings"
-
 	"golang.org/x/tools/go/ast/astutil"
 )
 
@@ -16,7 +14,7 @@ func (gt *genericTransformation) Prepare() error {
 	if gt.prepare == nil {
 		return nil
 	}
-	return gt.Prepare()
+	return gt.prepare()
 }
 
 func (gt *genericTransformation) Apply(context FileContext) error {
@@ -33,19 +31,20 @@ func (gt *genericTransformation) Finalize() error {
 	return gt.finalize()
 }
 
-// DropBuildIgnore removes // +build ignore comments from a file.
-func DropBuildIgnore() FileTransformation {
+// failingTransformation is a transformation that fails with the given error. It is used
+// to report invalid arguments of transformation constructors once the pipeline runs.
+func failingTransformation(err error) FileTransformation {
 	return &genericTransformation{
-		apply: func(context FileContext) error {
-			f := context.File
-			if len(f.Comments) > 0 && strings.Trim(f.Comments[0].Text(), " ") == "+build ignore" {
-				f.Comments = f.Comments[1:]
-			}
-			return nil
-		},
+		apply: func(FileContext) error { return err },
 	}
 }
 
+// DropBuildIgnore removes the ignore tag from the build constraints of a file, see
+// RemoveBuildTag.
+func DropBuildIgnore() FileTransformation {
+	return RemoveBuildTag("ignore")
+}
+
 // ChangePackageName changes the name of the package in a file.
 func ChangePackageName(name string) FileTransformation {
 	return &genericTransformation{
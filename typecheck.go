@@ -0,0 +1,68 @@
+package gotransform
+
+import (
+	"go/ast"
+	"go/build"
+	"go/importer"
+	"go/token"
+	"go/types"
+	"path/filepath"
+)
+
+// typeCheck type-checks all packages in the collection and stores the results in the
+// file contexts. Files are grouped into packages by their directory and package name.
+// Files excluded by their build constraints in the given build context are left without
+// type information, as they may redeclare what other files of the package declare.
+func typeCheck(inputPath string, fileset *token.FileSet, collection []FileContext, buildContext *build.Context) {
+	type packageKey struct{ dir, name string }
+	packages := make(map[packageKey][]int)
+	var order []packageKey
+	for i, context := range collection {
+		path := filepath.Join(inputPath, context.RelativePath)
+		if match, err := buildContext.MatchFile(filepath.Dir(path), filepath.Base(path)); err == nil && !match {
+			continue
+		}
+		key := packageKey{filepath.Dir(context.RelativePath), context.File.Name.Name}
+		if _, ok := packages[key]; !ok {
+			order = append(order, key)
+		}
+		packages[key] = append(packages[key], i)
+	}
+
+	sizes := types.SizesFor(buildContext.Compiler, buildContext.GOARCH)
+	if sizes == nil {
+		sizes = types.SizesFor("gc", buildContext.GOARCH)
+	}
+	config := &types.Config{
+		Importer: importer.ForCompiler(fileset, "source", nil),
+		Sizes:    sizes,
+		// collect all errors instead of stopping at the first one; the type information
+		// is still useful for the parts of the package that are well-typed.
+		Error: func(error) {},
+	}
+	for _, key := range order {
+		indices := packages[key]
+		files := make([]*ast.File, len(indices))
+		for i, idx := range indices {
+			files[i] = collection[idx].File
+		}
+		info := &types.Info{
+			Types:      make(map[ast.Expr]types.TypeAndValue),
+			Defs:       make(map[*ast.Ident]types.Object),
+			Uses:       make(map[*ast.Ident]types.Object),
+			Implicits:  make(map[ast.Node]types.Object),
+			Selections: make(map[*ast.SelectorExpr]*types.Selection),
+			Scopes:     make(map[ast.Node]*types.Scope),
+		}
+		path, err := ImportPathForDir(filepath.Join(inputPath, key.dir))
+		if err != nil {
+			// outside of a module, fall back to the package name
+			path = key.name
+		}
+		pkg, _ := config.Check(path, fileset, files, info)
+		for _, idx := range indices {
+			collection[idx].Package = pkg
+			collection[idx].Info = info
+		}
+	}
+}
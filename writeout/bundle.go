@@ -0,0 +1,61 @@
+package writeout
+
+import (
+	"bytes"
+	"path/filepath"
+
+	"github.com/chasingcarrots/gotransform"
+
+	"github.com/pkg/errors"
+)
+
+type bundle struct {
+	outputPath, fileName, prefix string
+	packages                     map[string][]gotransform.FileContext
+	order                        []string
+}
+
+// Bundle can be used instead of Transformation to write out all files of a package as a
+// single file, see gotransform.BundleFiles. The bundle for the files in a directory dir1/dir2
+// ends up in outputPath/dir1/dir2/fileName. The prefix is prepended to all unexported
+// package-level identifiers; leave it empty to keep them as they are.
+// Test files and files excluded by the build constraints are left out, see
+// gotransform.BundledFile.
+// Bundle only writes its output once all files have been transformed, so it should be
+// the last transformation in the pipeline.
+func Bundle(outputPath, fileName, prefix string) gotransform.FileTransformation {
+	return &bundle{
+		outputPath: outputPath,
+		fileName:   fileName,
+		prefix:     prefix,
+		packages:   make(map[string][]gotransform.FileContext),
+	}
+}
+
+func (b *bundle) Prepare() error { return nil }
+
+func (b *bundle) Apply(context gotransform.FileContext) error {
+	if !gotransform.BundledFile(context) {
+		return nil
+	}
+	dir := filepath.Dir(context.RelativePath)
+	if _, ok := b.packages[dir]; !ok {
+		b.order = append(b.order, dir)
+	}
+	b.packages[dir] = append(b.packages[dir], context)
+	return nil
+}
+
+func (b *bundle) Finalize() error {
+	for _, dir := range b.order {
+		src, err := gotransform.BundleFiles(b.packages[dir], b.prefix)
+		if err != nil {
+			return errors.Wrapf(err, "Bundle: Failed to bundle %s", dir)
+		}
+		path := filepath.Join(b.outputPath, dir, b.fileName)
+		if err := gotransform.WriteGoFile(path, bytes.NewBuffer(src)); err != nil {
+			return errors.Wrap(err, "Bundle: Failed to write file")
+		}
+	}
+	return nil
+}
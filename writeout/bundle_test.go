@@ -0,0 +1,89 @@
+package writeout
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/chasingcarrots/gotransform"
+)
+
+func TestBundle(t *testing.T) {
+	other := "windows"
+	if runtime.GOOS == "windows" {
+		other = "linux"
+	}
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  []string
+		skip  []string
+		err   string
+	}{
+		{
+			name: "test files and other platforms are skipped",
+			files: map[string]string{
+				"p/a.go":                      "package p\n\nfunc A() string { return osName }\n",
+				"p/a_test.go":                 "package p_test\n\nimport \"testing\"\n\nfunc TestA(t *testing.T) {}\n",
+				"p/x_" + runtime.GOOS + ".go": "package p\n\nconst osName = \"" + runtime.GOOS + "\"\n",
+				"p/x_" + other + ".go":        "package p\n\nconst osName = \"" + other + "\"\n",
+				"p/ignored.go":                "//go:build ignore\n\npackage main\n\nfunc main() {}\n",
+				"q/q_test.go":                 "package q\n\nfunc TestQ() {}\n",
+			},
+			want: []string{"func A()", "const osName = \"" + runtime.GOOS + "\""},
+			skip: []string{"TestA", "testing", other, "main"},
+		},
+		{
+			name: "several packages in a directory",
+			files: map[string]string{
+				"p/a.go": "package p\n",
+				"p/b.go": "package q\n",
+			},
+			err: "Files belong to different packages p and q",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			input := t.TempDir()
+			output := t.TempDir()
+			for name, content := range test.files {
+				path := filepath.Join(input, filepath.FromSlash(name))
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			err := gotransform.Apply(input, []gotransform.FileTransformation{Bundle(output, "bundle.go", "")})
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Errorf("Expected an error containing %q, got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			content, err := os.ReadFile(filepath.Join(output, "p", "bundle.go"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range test.want {
+				if !strings.Contains(string(content), want) {
+					t.Errorf("Expected the bundle to contain %q, got\n%s", want, content)
+				}
+			}
+			for _, skip := range test.skip {
+				if strings.Contains(string(content), skip) {
+					t.Errorf("Expected the bundle not to contain %q, got\n%s", skip, content)
+				}
+			}
+			if _, err := os.Stat(filepath.Join(output, "q")); !os.IsNotExist(err) {
+				t.Errorf("Expected no bundle for a directory of test files, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package writeout
+
+import (
+	"github.com/chasingcarrots/gotransform"
+
+	"github.com/pkg/errors"
+)
+
+// RewriteImports creates a transformation that rewrites all imports pointing into the
+// input path so that they point to the corresponding packages in the output path instead.
+// The import paths are derived from the module path in the go.mod file governing each
+// of the directories. Use this together with Transformation to relocate a package tree,
+// for example:
+//     rewrite, err := writeout.RewriteImports(inputPath, outputPath)
+//     ...
+//     transformations := []gotransform.FileTransformation{
+//         rewrite,
+//         writeout.Transformation(outputPath, "_gen"),
+//     }
+func RewriteImports(inputPath, outputPath string) (gotransform.FileTransformation, error) {
+	inputImport, err := gotransform.ImportPathForDir(inputPath)
+	if err != nil {
+		return nil, errors.Wrap(err, "RewriteImports: Failed to determine input import path")
+	}
+	outputImport, err := gotransform.ImportPathForDir(outputPath)
+	if err != nil {
+		return nil, errors.Wrap(err, "RewriteImports: Failed to determine output import path")
+	}
+	return gotransform.RewriteImports(map[string]string{inputImport: outputImport}), nil
+}
@@ -0,0 +1,130 @@
+package writeout
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+)
+
+// PathInfo describes a file whose output path is to be determined by a PathMapping.
+type PathInfo struct {
+	// Path is the path of the file relative to the input (or output) directory, e.g. dir1/dir2/file.go
+	Path string
+	// Dir is the directory part of Path, e.g. dir1/dir2, or . for files at the top level
+	Dir string
+	// Base is the file name without its extension, e.g. file
+	Base string
+	// Ext is the extension of the file including the dot, e.g. .go
+	Ext string
+	// Package is the name of the package declared in the file
+	Package string
+}
+
+func newPathInfo(path, packageName string) PathInfo {
+	path = filepath.ToSlash(path)
+	base := filepath.Base(path)
+	ext := filepath.Ext(base)
+	return PathInfo{
+		Path:    path,
+		Dir:     filepath.ToSlash(filepath.Dir(path)),
+		Base:    base[:len(base)-len(ext)],
+		Ext:     ext,
+		Package: packageName,
+	}
+}
+
+// PathMapping determines the path of the output for a file, relative to the output directory.
+type PathMapping func(PathInfo) (string, error)
+
+// KeepPath maps every file to its relative input path.
+func KeepPath(info PathInfo) (string, error) {
+	return info.Path, nil
+}
+
+// Flatten drops the directories of all files, i.e. all files end up directly in the output
+// directory.
+func Flatten(info PathInfo) (string, error) {
+	return info.Base + info.Ext, nil
+}
+
+// ByPackage places all files in a directory named after their package, dropping the
+// directories they come from.
+func ByPackage(info PathInfo) (string, error) {
+	return info.Package + "/" + info.Base + info.Ext, nil
+}
+
+// WithSuffix adds a suffix to the file name, right before its extension.
+func WithSuffix(suffix string) PathMapping {
+	return func(info PathInfo) (string, error) {
+		return addSuffix(info.Path, suffix), nil
+	}
+}
+
+// WithPrefix adds a prefix to the file name.
+func WithPrefix(prefix string) PathMapping {
+	return func(info PathInfo) (string, error) {
+		return pathJoin(info.Dir, prefix+info.Base+info.Ext), nil
+	}
+}
+
+// WithExtension replaces the extension of the file name, e.g. WithExtension(".txt").
+func WithExtension(ext string) PathMapping {
+	return func(info PathInfo) (string, error) {
+		return pathJoin(info.Dir, info.Base+ext), nil
+	}
+}
+
+// PathTemplate creates a mapping from a template that is executed with the PathInfo of a
+// file, e.g. "{{.Package}}/{{.Base}}_gen{{.Ext}}".
+func PathTemplate(text string) (PathMapping, error) {
+	tmpl, err := template.New("path").Parse(text)
+	if err != nil {
+		return nil, errors.Wrapf(err, "PathTemplate: Failed to parse %q", text)
+	}
+	return func(info PathInfo) (string, error) {
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, info); err != nil {
+			return "", errors.Wrapf(err, "PathTemplate: Failed to map %s", info.Path)
+		}
+		return buf.String(), nil
+	}, nil
+}
+
+// Chain combines several mappings by applying them one after the other, each to the result
+// of the previous one. For example, Chain(ByPackage, WithPrefix("gen_")) maps a/b/file.go
+// in package foo to foo/gen_file.go.
+func Chain(mappings ...PathMapping) PathMapping {
+	return func(info PathInfo) (string, error) {
+		for _, mapping := range mappings {
+			path, err := mapping(info)
+			if err != nil {
+				return "", err
+			}
+			info = newPathInfo(path, info.Package)
+		}
+		return info.Path, nil
+	}
+}
+
+func pathJoin(dir, file string) string {
+	if dir == "." || len(dir) == 0 {
+		return file
+	}
+	return dir + "/" + file
+}
+
+// mapPath applies a mapping and makes sure that the result stays within the output directory.
+func mapPath(mapping PathMapping, relativePath, packageName string) (string, error) {
+	path, err := mapping(newPathInfo(relativePath, packageName))
+	if err != nil {
+		return "", err
+	}
+	path = filepath.Clean(filepath.FromSlash(path))
+	if filepath.IsAbs(path) || path == "." || path == ".." || strings.HasPrefix(path, ".."+string(filepath.Separator)) {
+		return "", errors.Errorf("Path %s of %s is not within the output directory", path, relativePath)
+	}
+	return path, nil
+}
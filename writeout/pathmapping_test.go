@@ -0,0 +1,118 @@
+package writeout
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/chasingcarrots/gotransform"
+)
+
+func TestPathMappings(t *testing.T) {
+	template, err := PathTemplate("{{.Package}}/{{.Dir}}/{{.Base}}_gen{{.Ext}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		mapping PathMapping
+		path    string
+		want    string
+		err     string
+	}{
+		{name: "keep", mapping: KeepPath, path: "a/b/file.go", want: "a/b/file.go"},
+		{name: "flatten", mapping: Flatten, path: "a/b/file.go", want: "file.go"},
+		{name: "by package", mapping: ByPackage, path: "a/b/file.go", want: "pkg/file.go"},
+		{name: "suffix", mapping: WithSuffix("_gen"), path: "a/file.go", want: "a/file_gen.go"},
+		{name: "prefix", mapping: WithPrefix("gen_"), path: "a/file.go", want: "a/gen_file.go"},
+		{name: "prefix at the top level", mapping: WithPrefix("gen_"), path: "file.go", want: "gen_file.go"},
+		{name: "extension", mapping: WithExtension(".txt"), path: "a/file.go", want: "a/file.txt"},
+		{name: "template", mapping: template, path: "a/b/file.go", want: "pkg/a/b/file_gen.go"},
+		{name: "chain", mapping: Chain(ByPackage, WithPrefix("gen_"), WithSuffix("_x")), path: "a/b/file.go", want: "pkg/gen_file_x.go"},
+		{name: "cleaned", mapping: fixedPath("a/../b/./file.go"), path: "file.go", want: "b/file.go"},
+		{name: "parent directory", mapping: fixedPath("../file.go"), path: "file.go", err: "is not within the output directory"},
+		{name: "absolute", mapping: fixedPath(filepath.ToSlash(filepath.Join(os.TempDir(), "file.go"))), path: "file.go", err: "is not within the output directory"},
+		{name: "empty", mapping: fixedPath(""), path: "file.go", err: "is not within the output directory"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := mapPath(test.mapping, test.path, "pkg")
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Errorf("Expected an error containing %q, got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != filepath.FromSlash(test.want) {
+				t.Errorf("Expected %s, got %s", test.want, got)
+			}
+		})
+	}
+}
+
+// fixedPath maps every file to the given path.
+func fixedPath(path string) PathMapping {
+	return func(PathInfo) (string, error) { return path, nil }
+}
+
+func TestMappedTransformation(t *testing.T) {
+	tests := []struct {
+		name    string
+		mapping PathMapping
+		want    []string
+		err     string
+	}{
+		{
+			name:    "by package",
+			mapping: Chain(ByPackage, WithSuffix("_gen")),
+			want:    []string{"a/one_gen.go", "b/two_gen.go"},
+		},
+		{
+			name:    "collision",
+			mapping: Flatten,
+			err:     "are both mapped to one.go",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			input := t.TempDir()
+			output := t.TempDir()
+			files := map[string]string{
+				"x/one.go": "package a\n",
+				"y/one.go": "package a\n",
+				"y/two.go": "package b\n",
+			}
+			if test.err == "" {
+				delete(files, "y/one.go")
+			}
+			for name, content := range files {
+				path := filepath.Join(input, filepath.FromSlash(name))
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			err := gotransform.Apply(input, []gotransform.FileTransformation{MappedTransformation(output, test.mapping)})
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Errorf("Expected an error containing %q, got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, name := range test.want {
+				if _, err := os.Stat(filepath.Join(output, filepath.FromSlash(name))); err != nil {
+					t.Errorf("Expected %s to be written: %v", name, err)
+				}
+			}
+		})
+	}
+}
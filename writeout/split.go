@@ -0,0 +1,58 @@
+package writeout
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+
+	"github.com/chasingcarrots/gotransform"
+
+	"github.com/pkg/errors"
+)
+
+type split struct {
+	outputPath, suffix string
+	naming             gotransform.NamingConvention
+	// sources maps the output paths to the relative paths of the files split into them
+	sources map[string]string
+}
+
+// Split can be used instead of Transformation to write out each file split into one file per
+// top-level type, see gotransform.SplitFile. The parts of a file dir1/dir2/file.go end up in
+// outputPath/dir1/dir2, with the suffix added to each of their names; for parts of test files,
+// it is added before the _test.go suffix. It is an error if parts of two files are written to
+// the same path, e.g. when a.go declares the type B next to the file b.go.
+func Split(outputPath, suffix string, naming gotransform.NamingConvention) gotransform.FileTransformation {
+	return &split{outputPath: outputPath, suffix: suffix, naming: naming, sources: make(map[string]string)}
+}
+
+func (s *split) Prepare() error {
+	s.sources = make(map[string]string)
+	return nil
+}
+
+func (s *split) Finalize() error { return nil }
+
+func (s *split) Apply(context gotransform.FileContext) error {
+	parts, err := gotransform.SplitFile(context, s.naming)
+	if err != nil {
+		return errors.Wrap(err, "Split: Failed to split file")
+	}
+	dir := filepath.Dir(context.RelativePath)
+	for _, part := range parts {
+		fileName := addSuffix(part.FileName, s.suffix)
+		if base := strings.TrimSuffix(part.FileName, "_test.go"); base != part.FileName {
+			fileName = base + s.suffix + "_test.go"
+		}
+		relativePath := filepath.Join(dir, fileName)
+		if source, ok := s.sources[relativePath]; ok && source != context.RelativePath {
+			return errors.Errorf("Split: %s and %s are both split into %s", source, context.RelativePath, relativePath)
+		}
+		s.sources[relativePath] = context.RelativePath
+		path := filepath.Join(s.outputPath, relativePath)
+		if err := gotransform.WriteGoFile(path, bytes.NewBuffer(part.Source)); err != nil {
+			return errors.Wrap(err, "Split: Failed to write file")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package writeout
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/chasingcarrots/gotransform"
+)
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  []string
+		err   string
+	}{
+		{
+			name: "parts of test files",
+			files: map[string]string{
+				"p/p.go":      "package p\n\ntype Thing struct{}\n",
+				"p/p_test.go": "package p\n\ntype FakeThing struct{}\n",
+			},
+			want: []string{"p/thing_gen.go", "p/fake_thing_gen_test.go"},
+		},
+		{
+			name: "part colliding with another file",
+			files: map[string]string{
+				"p/a.go": "package p\n\nvar A int\n\ntype B struct{}\n",
+				"p/b.go": "package p\n\nvar C int\n",
+			},
+			err: "p/a.go and p/b.go are both split into p/b_gen.go",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			input := t.TempDir()
+			output := t.TempDir()
+			for name, content := range test.files {
+				path := filepath.Join(input, filepath.FromSlash(name))
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			err := gotransform.Apply(input, []gotransform.FileTransformation{Split(output, "_gen", gotransform.SnakeCase)})
+			if test.err != "" {
+				if err == nil || !strings.Contains(filepath.ToSlash(err.Error()), test.err) {
+					t.Errorf("Expected an error containing %q, got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, name := range test.want {
+				if _, err := os.Stat(filepath.Join(output, filepath.FromSlash(name))); err != nil {
+					t.Errorf("Expected %s to be written: %v", name, err)
+				}
+			}
+		})
+	}
+}
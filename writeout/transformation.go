@@ -13,30 +13,78 @@ import (
 )
 
 type writeOut struct {
-	outputPath, suffix string
+	outputPath string
+	mapping    PathMapping
+	// merge makes the transformation merge the files into existing ones
+	merge        bool
+	writeOptions gotransform.GoWriteOptions
+	// sources maps the output paths to the relative paths of the files written to them
+	sources map[string]string
 }
 
 // Transformation can be used at any stage to write each file out with an additional
 // suffix. Files are written out with their relative path, so a file dir1/dir2/file.go
-// will end up in outputPath/dir1/dir2/file.go
+// will end up in outputPath/dir1/dir2/file.go. Files from previous runs are not deleted,
+// use gotransform.Manifest for that.
 func Transformation(outputPath, suffix string) gotransform.FileTransformation {
-	return &writeOut{outputPath: outputPath, suffix: suffix}
+	return MappedTransformation(outputPath, WithSuffix(suffix))
+}
+
+// MappedTransformation is like Transformation, but determines the path of each file in the
+// output directory with the given mapping, e.g.
+//     MappedTransformation(outputPath, Chain(Flatten, WithSuffix("_gen")))
+// It is an error if two files are mapped to the same path.
+func MappedTransformation(outputPath string, mapping PathMapping) gotransform.FileTransformation {
+	return MappedTransformationWithOptions(outputPath, mapping, gotransform.DefaultGoWriteOptions())
+}
+
+// MappedTransformationWithOptions is like MappedTransformation, but formats and writes the
+// files with the given options instead of gotransform.DefaultGoWriteOptions.
+func MappedTransformationWithOptions(outputPath string, mapping PathMapping, writeOptions gotransform.GoWriteOptions) gotransform.FileTransformation {
+	return &writeOut{outputPath: outputPath, mapping: mapping, writeOptions: writeOptions, sources: make(map[string]string)}
+}
+
+// MergedTransformation is like MappedTransformation, but instead of overwriting the output
+// files, the declarations of each file are merged into the existing one, see
+// gotransform.MergeGoFile. Hand-written code in the output files is kept.
+func MergedTransformation(outputPath string, mapping PathMapping) gotransform.FileTransformation {
+	return MergedTransformationWithOptions(outputPath, mapping, gotransform.DefaultGoWriteOptions())
+}
+
+// MergedTransformationWithOptions is like MergedTransformation, but formats and writes the
+// files with the given options instead of gotransform.DefaultGoWriteOptions.
+func MergedTransformationWithOptions(outputPath string, mapping PathMapping, writeOptions gotransform.GoWriteOptions) gotransform.FileTransformation {
+	return &writeOut{outputPath: outputPath, mapping: mapping, merge: true, writeOptions: writeOptions, sources: make(map[string]string)}
 }
 
 func (wo *writeOut) Apply(context gotransform.FileContext) error {
+	relativePath, err := mapPath(wo.mapping, context.RelativePath, context.File.Name.Name)
+	if err != nil {
+		return errors.Wrap(err, "Write out: Failed to map path")
+	}
+	if source, ok := wo.sources[relativePath]; ok && source != context.RelativePath {
+		return errors.Errorf("Write out: %s and %s are both mapped to %s", source, context.RelativePath, relativePath)
+	}
+	wo.sources[relativePath] = context.RelativePath
+
 	var buf bytes.Buffer
 	if err := format.Node(&buf, context.FileSet, context.File); err != nil {
 		return errors.Wrap(err, "WriteOut: Failed to format file")
 	}
-	path := filepath.Join(wo.outputPath, addSuffix(context.RelativePath, wo.suffix))
-	if err := gotransform.WriteGoFile(path, &buf); err != nil {
+	path := filepath.Join(wo.outputPath, relativePath)
+	write := gotransform.WriteGoFileWithOptions
+	if wo.merge {
+		write = gotransform.WriteGoFileMergedWithOptions
+	}
+	if err := write(path, &buf, wo.writeOptions); err != nil {
 		return errors.Wrap(err, "Write out: Failed to write file")
 	}
 	return nil
 }
 
 func (wo *writeOut) Prepare() error {
-	return errors.Wrap(PrepareDir(wo.outputPath, wo.suffix), "Write out: Failed to prepare directory")
+	wo.sources = make(map[string]string)
+	return nil
 }
 
 func (wo *writeOut) Finalize() error { return nil }
@@ -50,7 +98,10 @@ func addSuffix(filename, suffix string) string {
 }
 
 // PrepareDir ensures that the given directory exists and removes all files with
-// the specified suffix from it.
+// the specified suffix from it. It always works on the file system, regardless of
+// gotransform.SetOutput. Note that this also deletes hand-written files that
+// happen to have the suffix; gotransform.Manifest only deletes files that the previous
+// run of the pipeline created and is the safer choice for cleaning up stale output.
 func PrepareDir(path, suffix string) error {
 	suffix = suffix + ".go"
 	if _, err := os.Stat(path); err != nil {